@@ -43,6 +43,8 @@ type ErrUnexpectedStatusCode struct {
 	Code   int
 	Body   string
 	ErrMsg string
+	// PostID is the ID of the existing post if the upload was a duplicate.
+	PostID int64
 }
 
 func (err ErrUnexpectedStatusCode) StatusCode() int {
@@ -253,6 +255,7 @@ func (c *Client) DoOnce(q *http.Request) (*http.Response, error) {
 			var errResp smolboard.ErrResponse
 			if json.Unmarshal(b, &errResp); errResp.Error != "" {
 				unexp.ErrMsg = errResp.Error
+				unexp.PostID = errResp.PostID
 			} else {
 				if len(b) > 100 {
 					unexp.Body = string(b[:97]) + "..."
@@ -319,6 +322,7 @@ Retry:
 			var errResp smolboard.ErrResponse
 			if json.Unmarshal(b, &errResp); errResp.Error != "" {
 				unexp.ErrMsg = errResp.Error
+				unexp.PostID = errResp.PostID
 			} else {
 				if len(b) > 100 {
 					unexp.Body = string(b[:97]) + "..."
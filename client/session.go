@@ -1,11 +1,16 @@
 package client
 
 import (
+	"bytes"
 	"fmt"
+	"mime/multipart"
+	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
 )
 
 // Session is the current smolboard HTTP session.
@@ -88,6 +93,26 @@ func (s *Session) DeleteMe() error {
 	return s.Client.Delete("/users/@me", nil, nil)
 }
 
+// Blacklist returns the current user's tag blacklist.
+func (s *Session) Blacklist() (t []string, err error) {
+	return t, s.Client.Get("/users/@me/blacklist", &t, nil)
+}
+
+// AddBlacklist adds a tag into the current user's blacklist. Posts with the tag
+// are hidden from the user unless they're overridden.
+func (s *Session) AddBlacklist(tag string) (t []string, err error) {
+	if err := smolboard.TagNameIsValid(tag); err != nil {
+		return nil, err
+	}
+
+	return t, s.Client.Post("/users/@me/blacklist", &t, url.Values{"t": {tag}})
+}
+
+// RemoveBlacklist removes a tag from the current user's blacklist.
+func (s *Session) RemoveBlacklist(tag string) (t []string, err error) {
+	return t, s.Client.Delete("/users/@me/blacklist", &t, url.Values{"t": {tag}})
+}
+
 // User gets a user with the given username.
 func (s *Session) User(username string) (u smolboard.UserPart, err error) {
 	return u, s.Client.Get(fmt.Sprintf("/users/%s", url.PathEscape(username)), &u, nil)
@@ -134,6 +159,14 @@ func (s *Session) Post(id int64) (p smolboard.PostExtended, err error) {
 	return p, s.Client.Get(fmt.Sprintf("/posts/%d", id), &p, nil)
 }
 
+// PostShowBlacklisted is similar to Post, but it overrides the user's tag
+// blacklist.
+func (s *Session) PostShowBlacklisted(id int64) (p smolboard.PostExtended, err error) {
+	return p, s.Client.Get(fmt.Sprintf("/posts/%d", id), &p, url.Values{
+		"showblacklisted": {"1"},
+	})
+}
+
 // Posts returns the paginated post list. Count is defaulted to 25.
 func (s *Session) Posts(count, page int) (p smolboard.SearchResults, err error) {
 	return s.PostSearch("", count, page)
@@ -141,15 +174,64 @@ func (s *Session) Posts(count, page int) (p smolboard.SearchResults, err error)
 
 // PostSearch is similar to Posts but with searching.
 func (s *Session) PostSearch(q string, count, page int) (p smolboard.SearchResults, err error) {
-	if count == 0 {
-		count = 25
+	return s.SearchPosts(PostSearchParams{
+		Query: q,
+		Count: count,
+		Page:  page,
+	})
+}
+
+// PostSearchParams is the parameters for searching posts.
+type PostSearchParams struct {
+	Query string
+	// Order overrides the order in the query if it's not zero.
+	Order smolboard.Order
+	// Count is defaulted to 25.
+	Count int
+	Page  int
+	// Before and After are the post IDs to paginate from, usually the cursors
+	// from the last results. The page is counted from them if either is given.
+	Before int64
+	After  int64
+	// ShowBlacklisted overrides the user's tag blacklist.
+	ShowBlacklisted bool
+	// Facets asks for the related tags of the results. They are only given for
+	// the first page.
+	Facets bool
+}
+
+// SearchPosts is similar to PostSearch but with more parameters.
+func (s *Session) SearchPosts(params PostSearchParams) (p smolboard.SearchResults, err error) {
+	if params.Count == 0 {
+		params.Count = 25
 	}
 
-	return p, s.Client.Get("/posts", &p, url.Values{
-		"q": {q},
-		"c": {strconv.Itoa(count)},
-		"p": {strconv.Itoa(page)},
-	})
+	var v = url.Values{
+		"q": {params.Query},
+		"c": {strconv.Itoa(params.Count)},
+		"p": {strconv.Itoa(params.Page)},
+	}
+
+	if !params.Order.IsZero() {
+		v.Set("o", params.Order.String())
+	}
+
+	if params.Before != 0 {
+		v.Set("before", strconv.FormatInt(params.Before, 10))
+	}
+	if params.After != 0 {
+		v.Set("after", strconv.FormatInt(params.After, 10))
+	}
+
+	if params.ShowBlacklisted {
+		v.Set("showblacklisted", "1")
+	}
+
+	if params.Facets {
+		v.Set("facets", "1")
+	}
+
+	return p, s.Client.Get("/posts", &p, v)
 }
 
 // PostDirectPath returns the direct path to the post's content.
@@ -177,6 +259,251 @@ func (s *Session) SetPostPermission(postID int64, p smolboard.Permission) error
 	)
 }
 
+// EditPost changes the given post's title, description or source. Nil fields
+// are left unchanged.
+func (s *Session) EditPost(postID int64, e smolboard.PostEdit) error {
+	var v = url.Values{}
+
+	if e.Title != nil {
+		v.Set("title", *e.Title)
+	}
+	if e.Description != nil {
+		v.Set("description", *e.Description)
+	}
+	if e.Source != nil {
+		v.Set("source", *e.Source)
+	}
+	if e.Parent != nil {
+		v.Set("parent", strconv.FormatInt(*e.Parent, 10))
+	}
+
+	return s.Client.Request("PATCH", fmt.Sprintf("/posts/%d", postID), nil, v)
+}
+
+// SearchImage returns the visible posts that look like the image, most similar
+// first. The distance is the maximum number of bits that differ between the
+// perceptual hashes; refer to smolboard.DefaultSimilarDistance.
+func (s *Session) SearchImage(
+	name string, image []byte, distance int) (p []smolboard.SimilarPost, err error) {
+
+	var body bytes.Buffer
+	var w = multipart.NewWriter(&body)
+
+	f, err := w.CreateFormFile("file", name)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create form file")
+	}
+
+	if _, err := f.Write(image); err != nil {
+		return nil, errors.Wrap(err, "Failed to write form file")
+	}
+
+	if err := w.WriteField("d", strconv.Itoa(distance)); err != nil {
+		return nil, errors.Wrap(err, "Failed to write form field")
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrap(err, "Failed to close form")
+	}
+
+	return p, s.Client.DoJSON(&p, func() (*http.Request, error) {
+		q, err := http.NewRequestWithContext(
+			s.Client.ctx, "POST", s.Endpoint("/search/image"), bytes.NewReader(body.Bytes()),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create request")
+		}
+
+		q.Header.Set("Content-Type", w.FormDataContentType())
+		return q, nil
+	})
+}
+
+// EditPosts applies the batch edit to each post in one request and returns the
+// result of each post in the same order.
+func (s *Session) EditPosts(b smolboard.PostBatch) (r []smolboard.PostBatchResult, err error) {
+	var v = url.Values{
+		"t": b.AddTags,
+		"u": b.RemoveTags,
+	}
+
+	for _, id := range b.PostIDs {
+		v.Add("id", strconv.FormatInt(id, 10))
+	}
+
+	if b.Permission != nil {
+		v.Set("p", b.Permission.StringInt())
+	}
+
+	return r, s.Client.Post("/posts/batch", &r, v)
+}
+
+// PostHistory returns the changes to the post's tags, newest first.
+func (s *Session) PostHistory(postID int64) (e []smolboard.TagEdit, err error) {
+	return e, s.Client.Get(fmt.Sprintf("/posts/%d/history", postID), &e, nil)
+}
+
+// PostHistoryShowBlacklisted is similar to PostHistory, but it overrides the
+// user's tag blacklist.
+func (s *Session) PostHistoryShowBlacklisted(postID int64) (e []smolboard.TagEdit, err error) {
+	return e, s.Client.Get(fmt.Sprintf("/posts/%d/history", postID), &e, url.Values{
+		"showblacklisted": {"1"},
+	})
+}
+
+// RevertPostTags reverts the post's tags to what they were at the given time
+// and returns the reverted post. Only administrators can do this.
+func (s *Session) RevertPostTags(postID int64, t time.Time) (p smolboard.PostExtended, err error) {
+	return p, s.Client.Post(fmt.Sprintf("/posts/%d/history/revert", postID), &p, url.Values{
+		"t": {t.Format(time.RFC3339Nano)},
+	})
+}
+
+// FavoritePost adds the post to the current user's favorites.
+func (s *Session) FavoritePost(postID int64) error {
+	return s.Client.Request("PUT", fmt.Sprintf("/posts/%d/favorite", postID), nil, nil)
+}
+
+// UnfavoritePost removes the post from the current user's favorites.
+func (s *Session) UnfavoritePost(postID int64) error {
+	return s.Client.Delete(fmt.Sprintf("/posts/%d/favorite", postID), nil, nil)
+}
+
+// VotePost sets the current user's vote on the post. VoteNone removes the vote.
+func (s *Session) VotePost(postID int64, vote smolboard.Vote) error {
+	if !vote.IsValid() {
+		return smolboard.ErrInvalidVote
+	}
+
+	var path = fmt.Sprintf("/posts/%d/vote", postID)
+
+	if vote == smolboard.VoteNone {
+		return s.Client.Delete(path, nil, nil)
+	}
+
+	return s.Client.Request("PUT", path, nil, url.Values{
+		"v": {strconv.Itoa(int(vote))},
+	})
+}
+
+// Comments returns a page of the post's comments, oldest first. Count is
+// defaulted to 25.
+func (s *Session) Comments(postID int64, count, page int) (smolboard.CommentList, error) {
+	return s.comments(postID, count, page, url.Values{})
+}
+
+// CommentsShowBlacklisted is similar to Comments, but it overrides the user's
+// tag blacklist.
+func (s *Session) CommentsShowBlacklisted(postID int64, count, page int) (smolboard.CommentList, error) {
+	return s.comments(postID, count, page, url.Values{"showblacklisted": {"1"}})
+}
+
+func (s *Session) comments(postID int64, count, page int, v url.Values) (c smolboard.CommentList, err error) {
+	if count == 0 {
+		count = 25
+	}
+
+	v.Set("c", strconv.Itoa(count))
+	v.Set("p", strconv.Itoa(page))
+
+	return c, s.Client.Get(fmt.Sprintf("/posts/%d/comments", postID), &c, v)
+}
+
+// AddComment comments on the post.
+func (s *Session) AddComment(postID int64, body string) (c smolboard.Comment, err error) {
+	if err := smolboard.CommentIsValid(body); err != nil {
+		return c, err
+	}
+
+	return c, s.Client.Post(fmt.Sprintf("/posts/%d/comments", postID), &c, url.Values{
+		"body": {body},
+	})
+}
+
+// DeleteComment deletes the comment.
+func (s *Session) DeleteComment(id int64) error {
+	return s.Client.Delete(fmt.Sprintf("/comments/%d", id), nil, nil)
+}
+
+// Pools returns a page of the pools, newest first. Count is defaulted to 25.
+func (s *Session) Pools(count, page int) (p smolboard.PoolList, err error) {
+	if count == 0 {
+		count = 25
+	}
+
+	return p, s.Client.Get("/pools", &p, url.Values{
+		"c": {strconv.Itoa(count)},
+		"p": {strconv.Itoa(page)},
+	})
+}
+
+// Pool returns the pool with its posts in order.
+func (s *Session) Pool(id int64) (p smolboard.PoolExtended, err error) {
+	return p, s.Client.Get(fmt.Sprintf("/pools/%d", id), &p, nil)
+}
+
+// PoolShowBlacklisted is similar to Pool, but it overrides the user's tag
+// blacklist.
+func (s *Session) PoolShowBlacklisted(id int64) (p smolboard.PoolExtended, err error) {
+	return p, s.Client.Get(fmt.Sprintf("/pools/%d", id), &p, url.Values{
+		"showblacklisted": {"1"},
+	})
+}
+
+// CreatePool creates an empty pool.
+func (s *Session) CreatePool(
+	name, description string, perm smolboard.Permission) (p smolboard.Pool, err error) {
+
+	return p, s.Client.Post("/pools", &p, url.Values{
+		"name":        {name},
+		"description": {description},
+		"p":           {perm.StringInt()},
+	})
+}
+
+// EditPool changes the pool. Nil fields are left unchanged.
+func (s *Session) EditPool(id int64, e smolboard.PoolEdit) error {
+	var v = url.Values{}
+
+	if e.Name != nil {
+		v.Set("name", *e.Name)
+	}
+	if e.Description != nil {
+		v.Set("description", *e.Description)
+	}
+	if e.Permission != nil {
+		v.Set("p", e.Permission.StringInt())
+	}
+
+	return s.Client.Request("PATCH", fmt.Sprintf("/pools/%d", id), nil, v)
+}
+
+// DeletePool deletes the pool but not its posts.
+func (s *Session) DeletePool(id int64) error {
+	return s.Client.Delete(fmt.Sprintf("/pools/%d", id), nil, nil)
+}
+
+// AddPoolPost adds the post to the end of the pool.
+func (s *Session) AddPoolPost(poolID, postID int64) error {
+	return s.Client.Request("PUT", fmt.Sprintf("/pools/%d/posts/%d", poolID, postID), nil, nil)
+}
+
+// RemovePoolPost removes the post from the pool.
+func (s *Session) RemovePoolPost(poolID, postID int64) error {
+	return s.Client.Delete(fmt.Sprintf("/pools/%d/posts/%d", poolID, postID), nil, nil)
+}
+
+// ReorderPool sorts the pool's posts in the order of the given post IDs, which
+// must have all of the pool's posts.
+func (s *Session) ReorderPool(poolID int64, postIDs []int64) error {
+	var v = url.Values{}
+	for _, id := range postIDs {
+		v.Add("id", strconv.FormatInt(id, 10))
+	}
+
+	return s.Client.Request("PUT", fmt.Sprintf("/pools/%d/order", poolID), nil, v)
+}
+
 // TagPost adds a tag to a post.
 func (s *Session) TagPost(postID int64, tag string) error {
 	if err := smolboard.TagIsValid(tag); err != nil {
@@ -190,7 +517,7 @@ func (s *Session) TagPost(postID int64, tag string) error {
 
 // UntagPost removes a tag from a post.
 func (s *Session) UntagPost(postID int64, tag string) error {
-	if err := smolboard.TagIsValid(tag); err != nil {
+	if err := smolboard.TagNameIsValid(tag); err != nil {
 		return err
 	}
 
@@ -199,6 +526,173 @@ func (s *Session) UntagPost(postID int64, tag string) error {
 	})
 }
 
+// SearchTags returns at most 25 tags that start with the given prefix, sorted
+// by their count. An empty prefix returns the most used tags.
+func (s *Session) SearchTags(prefix string) (t []smolboard.PostTag, err error) {
+	return t, s.Client.Get("/tags", &t, url.Values{
+		"q": {prefix},
+	})
+}
+
+// TagAliases returns the aliases of the given tag.
+func (s *Session) TagAliases(tag string) (a []smolboard.TagAlias, err error) {
+	return a, s.Client.Get(tagPath(tag, "aliases"), &a, nil)
+}
+
+// AddTagAlias makes the alias an alias of the given tag. This requires the
+// administrator permission.
+func (s *Session) AddTagAlias(tag, alias string) error {
+	if err := smolboard.TagNameIsValid(alias); err != nil {
+		return err
+	}
+
+	return s.Client.Post(tagPath(tag, "aliases"), nil, url.Values{
+		"a": {alias},
+	})
+}
+
+// DeleteTagAlias deletes the alias of the given tag. This requires the
+// administrator permission.
+func (s *Session) DeleteTagAlias(tag, alias string) error {
+	return s.Client.Delete(tagPath(tag, "aliases"), nil, url.Values{
+		"a": {alias},
+	})
+}
+
+// TagImplications returns the tags directly implied by the given tag.
+func (s *Session) TagImplications(tag string) (i []smolboard.TagImplication, err error) {
+	return i, s.Client.Get(tagPath(tag, "implications"), &i, nil)
+}
+
+// AddTagImplication makes the tag imply the implied tag. This requires the
+// administrator permission.
+func (s *Session) AddTagImplication(tag, implied string) error {
+	if err := smolboard.TagIsValid(implied); err != nil {
+		return err
+	}
+
+	return s.Client.Post(tagPath(tag, "implications"), nil, url.Values{
+		"t": {implied},
+	})
+}
+
+// DeleteTagImplication deletes the implication from the tag. This requires the
+// administrator permission.
+func (s *Session) DeleteTagImplication(tag, implied string) error {
+	return s.Client.Delete(tagPath(tag, "implications"), nil, url.Values{
+		"t": {implied},
+	})
+}
+
+// BackfillTagImplications tags existing posts with the tags implied by the
+// given tag and returns the number of tags added. This requires the
+// administrator permission.
+func (s *Session) BackfillTagImplications(tag string) (added int64, err error) {
+	return added, s.Client.Post(tagPath(tag, "implications", "backfill"), &added, nil)
+}
+
+// RenameTag renames the tag on all posts. This requires the administrator
+// permission.
+func (s *Session) RenameTag(from, to string) error {
+	if err := smolboard.TagIsValid(to); err != nil {
+		return err
+	}
+
+	return s.Client.Request("PATCH", tagPath(from), nil, url.Values{
+		"n": {to},
+	})
+}
+
+// MergeTags moves the posts tagged with the from tag to the into tag. This
+// requires the administrator permission.
+func (s *Session) MergeTags(from, into string) error {
+	if err := smolboard.TagIsValid(into); err != nil {
+		return err
+	}
+
+	return s.Client.Post(tagPath(from, "merge"), nil, url.Values{
+		"t": {into},
+	})
+}
+
+// TagDescription returns the latest revision of the tag's description.
+func (s *Session) TagDescription(tag string) (r smolboard.TagRevision, err error) {
+	return r, s.Client.Get(tagPath(tag, "description"), &r, nil)
+}
+
+// TagRevisions returns all revisions of the tag's description, latest first.
+func (s *Session) TagRevisions(tag string) (r []smolboard.TagRevision, err error) {
+	return r, s.Client.Get(tagPath(tag, "revisions"), &r, nil)
+}
+
+// SetTagDescription sets the tag's description and returns the new revision.
+// This requires the trusted permission.
+func (s *Session) SetTagDescription(tag, description string) (r smolboard.TagRevision, err error) {
+	if len(description) > smolboard.MaxTagDescriptionLen {
+		return r, smolboard.ErrTagDescriptionTooLong
+	}
+
+	return r, s.Client.Request("PUT", tagPath(tag, "description"), &r, url.Values{
+		"d": {description},
+	})
+}
+
+// TagCategories returns all tag categories sorted by their order.
+func (s *Session) TagCategories() (c []smolboard.TagCategory, err error) {
+	return c, s.Client.Get("/categories", &c, nil)
+}
+
+// SetTagCategory creates or overrides the tag category. This requires the
+// administrator permission.
+func (s *Session) SetTagCategory(c smolboard.TagCategory) error {
+	if err := smolboard.TagCategoryIsValid(c); err != nil {
+		return err
+	}
+
+	return s.Client.Request("PUT", "/categories/"+url.PathEscape(c.Name), nil, url.Values{
+		"c": {c.Color},
+		"o": {strconv.Itoa(c.SortOrder)},
+	})
+}
+
+// DeleteTagCategory deletes the tag category. This requires the administrator
+// permission.
+func (s *Session) DeleteTagCategory(name string) error {
+	return s.Client.Delete("/categories/"+url.PathEscape(name), nil, nil)
+}
+
+// RestrictedTags returns all restricted tags.
+func (s *Session) RestrictedTags() (t []smolboard.RestrictedTag, err error) {
+	return t, s.Client.Get("/restrictedtags", &t, nil)
+}
+
+// SetRestrictedTag restricts the tag to users with at least the given
+// permission. This requires the administrator permission.
+func (s *Session) SetRestrictedTag(tag string, p smolboard.Permission) error {
+	if err := smolboard.TagNameIsValid(tag); err != nil {
+		return err
+	}
+
+	return s.Client.Request("PUT", "/restrictedtags/"+url.PathEscape(tag), nil, url.Values{
+		"p": {p.StringInt()},
+	})
+}
+
+// DeleteRestrictedTag removes the tag's restriction. This requires the
+// administrator permission.
+func (s *Session) DeleteRestrictedTag(tag string) error {
+	return s.Client.Delete("/restrictedtags/"+url.PathEscape(tag), nil, nil)
+}
+
+// tagPath returns the escaped API path to the given tag and its subpaths.
+func tagPath(tag string, paths ...string) string {
+	var path = "/tags/" + url.PathEscape(tag)
+	for _, p := range paths {
+		path += "/" + p
+	}
+	return path
+}
+
 // Tokens returns a list of tokens along with extra bits returned from the
 // server to assist in getting information without extra queries.
 func (s *Session) Tokens() (tl smolboard.TokenList, err error) {
@@ -5,6 +5,7 @@ import (
 	"math"
 	"strconv"
 
+	"github.com/diamondburned/smolboard/client"
 	"github.com/diamondburned/smolboard/frontend/frontserver/render"
 	"github.com/pkg/errors"
 )
@@ -29,7 +30,8 @@ var Component = render.Component{
 	},
 }
 
-// Page returns a 1-indexed page count parsed from "p".
+// Page returns a 1-indexed page count parsed from "p". If the page is paged
+// from a cursor, then it's counted from the page in "from" instead.
 func Page(r *render.Request) (int, error) {
 	var page = 1
 	if str := r.FormValue("p"); str != "" {
@@ -39,5 +41,81 @@ func Page(r *render.Request) (int, error) {
 		}
 		page = p
 	}
+
+	before, after, err := cursor(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if before == 0 && after == 0 {
+		return page, nil
+	}
+
+	var from = 1
+	if str := r.FormValue("from"); str != "" {
+		f, err := strconv.Atoi(str)
+		if err != nil {
+			return 0, errors.Wrap(err, "Failed to parse from page")
+		}
+		from = f
+	}
+
+	if after != 0 {
+		page = from + 1
+	} else {
+		page = from - 1
+	}
+
+	// Posts could've been uploaded since, so the count may go past the first
+	// page.
+	if page < 1 {
+		page = 1
+	}
+
 	return page, nil
 }
+
+// SearchParams returns the parameters to search posts for the current page.
+// The cursors from "before" and "after" are used over the page if there are
+// any.
+func SearchParams(r *render.Request) (client.PostSearchParams, error) {
+	page, err := Page(r)
+	if err != nil {
+		return client.PostSearchParams{}, err
+	}
+
+	before, after, err := cursor(r)
+	if err != nil {
+		return client.PostSearchParams{}, err
+	}
+
+	var params = client.PostSearchParams{
+		Count:  PageSize,
+		Before: before,
+		After:  after,
+	}
+
+	if before == 0 && after == 0 {
+		params.Page = page - 1
+	}
+
+	return params, nil
+}
+
+func cursor(r *render.Request) (before, after int64, err error) {
+	if str := r.FormValue("before"); str != "" {
+		before, err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "Failed to parse before cursor")
+		}
+	}
+
+	if str := r.FormValue("after"); str != "" {
+		after, err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "Failed to parse after cursor")
+		}
+	}
+
+	return
+}
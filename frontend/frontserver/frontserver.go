@@ -6,10 +6,12 @@ import (
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/errorpage"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/gallery"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/home"
+	"github.com/diamondburned/smolboard/frontend/frontserver/pages/pool"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/post"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/settings"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/signin"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/signup"
+	"github.com/diamondburned/smolboard/frontend/frontserver/pages/tag"
 	"github.com/diamondburned/smolboard/frontend/frontserver/render"
 )
 
@@ -56,6 +58,8 @@ func bind(r *render.Mux) {
 	r.Get("/", home.Render)
 	r.Mount("/posts", gallery.Mount)
 	r.Mount("/posts/{id}", post.Mount)
+	r.Mount("/tags/{name}", tag.Mount)
+	r.Mount("/pools", pool.Mount)
 	r.Mount("/signin", signin.Mount)
 	r.Mount("/signup", signup.Mount)
 	r.Mount("/signout", signin.MountSignOut)
@@ -0,0 +1,223 @@
+// Package markdown renders a small subset of Markdown into HTML. HTML in the
+// source is always escaped and links are checked, so the output is safe to put
+// into a page as-is.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRe  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	listItemRe = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+
+	linkRe   = regexp.MustCompile(`\[([^\[\]]+)\]\(([^()\s]+)\)`)
+	strongRe = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	emRe     = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+)
+
+// Render renders the Markdown source into sanitized HTML. The supported syntax
+// is paragraphs, headings, unordered lists, fenced code blocks, inline code,
+// bold, italics and links. Links may only point to HTTP URLs or to absolute
+// paths on the same site.
+func Render(src string) template.HTML {
+	var r renderer
+
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+
+	for _, line := range strings.Split(src, "\n") {
+		r.line(line)
+	}
+
+	r.flush()
+
+	if r.code != nil {
+		r.flushCode()
+	}
+
+	return template.HTML(r.out.String())
+}
+
+type renderer struct {
+	out  strings.Builder
+	para []string
+	list []string
+	// code is non-nil if the renderer is inside a fenced code block.
+	code []string
+}
+
+func (r *renderer) line(line string) {
+	var trimmed = strings.TrimSpace(line)
+
+	if strings.HasPrefix(trimmed, "```") {
+		if r.code != nil {
+			r.flushCode()
+		} else {
+			r.flush()
+			r.code = []string{}
+		}
+		return
+	}
+
+	if r.code != nil {
+		r.code = append(r.code, line)
+		return
+	}
+
+	if trimmed == "" {
+		r.flush()
+		return
+	}
+
+	if m := headingRe.FindStringSubmatch(trimmed); m != nil {
+		r.flush()
+
+		// Start from h3, as the page already has bigger headings.
+		var level = len(m[1]) + 2
+		if level > 6 {
+			level = 6
+		}
+
+		fmt.Fprintf(&r.out, "<h%d>%s</h%d>", level, inline(m[2]), level)
+		return
+	}
+
+	if m := listItemRe.FindStringSubmatch(trimmed); m != nil {
+		r.flushPara()
+		r.list = append(r.list, m[1])
+		return
+	}
+
+	r.flushList()
+	r.para = append(r.para, trimmed)
+}
+
+func (r *renderer) flush() {
+	r.flushPara()
+	r.flushList()
+}
+
+func (r *renderer) flushPara() {
+	if len(r.para) == 0 {
+		return
+	}
+
+	r.out.WriteString("<p>")
+	r.out.WriteString(inline(strings.Join(r.para, "\n")))
+	r.out.WriteString("</p>")
+
+	r.para = r.para[:0]
+}
+
+func (r *renderer) flushList() {
+	if len(r.list) == 0 {
+		return
+	}
+
+	r.out.WriteString("<ul>")
+	for _, item := range r.list {
+		r.out.WriteString("<li>")
+		r.out.WriteString(inline(item))
+		r.out.WriteString("</li>")
+	}
+	r.out.WriteString("</ul>")
+
+	r.list = r.list[:0]
+}
+
+func (r *renderer) flushCode() {
+	r.out.WriteString("<pre><code>")
+	r.out.WriteString(html.EscapeString(strings.Join(r.code, "\n")))
+	r.out.WriteString("</code></pre>")
+
+	r.code = nil
+}
+
+// inline renders the inline syntax of a block.
+func inline(src string) string {
+	var out strings.Builder
+
+	// Code spans are taken literally, so split them out first.
+	for {
+		start := strings.IndexByte(src, '`')
+		if start == -1 {
+			break
+		}
+
+		end := strings.IndexByte(src[start+1:], '`')
+		if end == -1 {
+			break
+		}
+		end += start + 1
+
+		out.WriteString(links(src[:start]))
+		out.WriteString("<code>")
+		out.WriteString(html.EscapeString(src[start+1 : end]))
+		out.WriteString("</code>")
+
+		src = src[end+1:]
+	}
+
+	out.WriteString(links(src))
+	return out.String()
+}
+
+// links renders links in the source. Links with disallowed URLs are left as
+// text.
+func links(src string) string {
+	var out strings.Builder
+	var last int
+
+	for _, m := range linkRe.FindAllStringSubmatchIndex(src, -1) {
+		href, ok := safeURL(src[m[4]:m[5]])
+		if !ok {
+			continue
+		}
+
+		out.WriteString(emphasis(src[last:m[0]]))
+		fmt.Fprintf(&out, `<a href="%s" rel="nofollow noopener">%s</a>`,
+			html.EscapeString(href), emphasis(src[m[2]:m[3]]))
+
+		last = m[1]
+	}
+
+	out.WriteString(emphasis(src[last:]))
+	return out.String()
+}
+
+// emphasis escapes the text and renders bold and italics.
+func emphasis(text string) string {
+	text = html.EscapeString(text)
+	text = strongRe.ReplaceAllString(text, "<strong>$1</strong>")
+	text = emRe.ReplaceAllString(text, "<em>$1</em>")
+	return text
+}
+
+// safeURL returns the URL if it's an HTTP URL or an absolute path.
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "":
+		// Disallow protocol-relative URLs, which may point to other sites.
+		if u.Host != "" || !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	return u.String(), true
+}
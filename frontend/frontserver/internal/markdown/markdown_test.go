@@ -0,0 +1,43 @@
+package markdown
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	var tests = []struct {
+		in, out string
+	}{{
+		in:  "# Cat\nA **small** *animal*.\n\nSee `cat_(animal)`.",
+		out: "<h3>Cat</h3><p>A <strong>small</strong> <em>animal</em>.</p><p>See <code>cat_(animal)</code>.</p>",
+	}, {
+		in:  "- [Wiki](https://example.com/a?b=1&c=2)\n- [Posts](/posts?q=cat)",
+		out: `<ul><li><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener">Wiki</a></li><li><a href="/posts?q=cat" rel="nofollow noopener">Posts</a></li></ul>`,
+	}, {
+		in:  "```\n<b>code</b>\n```",
+		out: "<pre><code>&lt;b&gt;code&lt;/b&gt;</code></pre>",
+	}}
+
+	for _, test := range tests {
+		if out := Render(test.in); string(out) != test.out {
+			t.Errorf("Unexpected render of %q:\n%s", test.in, out)
+		}
+	}
+}
+
+func TestRenderSanitized(t *testing.T) {
+	var tests = map[string]string{
+		`<script>alert(1)</script>`:    `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`,
+		`[x](javascript:alert(1))`:     `<p>[x](javascript:alert(1))</p>`,
+		`[x](javascript:alert)`:        `<p>[x](javascript:alert)</p>`,
+		`[x](//evil.example.com)`:      `<p>[x](//evil.example.com)</p>`,
+		`[x](/a"onmouseover="alert)`:   `<p><a href="/a%22onmouseover=%22alert" rel="nofollow noopener">x</a></p>`,
+		`[<img src=x>](https://a.com)`: `<p><a href="https://a.com" rel="nofollow noopener">&lt;img src=x&gt;</a></p>`,
+	}
+
+	for in, expect := range tests {
+		if out := Render(in); string(out) != expect {
+			t.Errorf("Unexpected render of %q:\n%s", in, out)
+		}
+	}
+}
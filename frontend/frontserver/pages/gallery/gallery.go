@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/diamondburned/smolboard/frontend/frontserver/components/footer"
 	"github.com/diamondburned/smolboard/frontend/frontserver/components/nav"
 	"github.com/diamondburned/smolboard/frontend/frontserver/components/pager"
+	"github.com/diamondburned/smolboard/frontend/frontserver/internal/markdown"
 	"github.com/diamondburned/smolboard/frontend/frontserver/internal/unblur"
 	"github.com/diamondburned/smolboard/frontend/frontserver/render"
 	"github.com/diamondburned/smolboard/smolboard"
@@ -30,11 +33,24 @@ var tmpl = render.BuildPage("home", render.Page{
 		"footer": footer.Component,
 	},
 	Functions: map[string]interface{}{
-		"isImage": func(ctype string) bool { return genericMIME(ctype) == "image" },
-		"isVideo": func(ctype string) bool { return genericMIME(ctype) == "video" },
+		"isImage":  func(ctype string) bool { return genericMIME(ctype) == "image" },
+		"isVideo":  func(ctype string) bool { return genericMIME(ctype) == "video" },
+		"markdown": markdown.Render,
 	},
 })
 
+var similarTmpl = render.BuildPage("similar", render.Page{
+	Template: "pages/gallery/similar.html",
+	Components: map[string]render.Component{
+		"nav":    nav.Component,
+		"footer": footer.Component,
+	},
+})
+
+// nearDupsCookie is the cookie that carries the near-duplicates of the
+// uploaded posts to the gallery page after uploading.
+const nearDupsCookie = "neardups"
+
 const MaxThumbSize = 300
 
 func genericMIME(mime string) string {
@@ -48,17 +64,74 @@ type renderCtx struct {
 	render.CommonCtx
 	smolboard.SearchResults
 
-	Query string   // ?q=X
-	Page  int      // ?p=X
-	Types []string // MIME types
+	Query string          // ?q=X
+	Order smolboard.Order // ?o=X
+	Page  int             // ?p=X
+	Types []string        // MIME types
+
+	// Tag is the searched tag if the query only has one. TagDescription is its
+	// description.
+	Tag            string
+	TagDescription string
 
 	DefaultUploadPerm smolboard.Permission
+
+	// NearDuplicates contains the IDs of the existing posts that look almost
+	// the same as the posts that were just uploaded.
+	NearDuplicates []int64
+}
+
+type similarCtx struct {
+	renderCtx
+	Similar []smolboard.SimilarPost
+}
+
+// TagPath returns the path to the searched tag's wiki page.
+func (r renderCtx) TagPath() string {
+	return "/tags/" + url.PathEscape(r.Tag)
+}
+
+// RelatedPath returns the path to the search narrowed down to the related tag.
+func (r renderCtx) RelatedPath(tag string) string {
+	var query = smolboard.EscapeTag(tag)
+	if r.Query != "" {
+		query = r.Query + " " + query
+	}
+
+	v := url.Values{"q": {query}}
+	if o := r.OrderValue(); o != "" {
+		v.Set("o", o)
+	}
+
+	return "/posts?" + v.Encode()
 }
 
 func (r renderCtx) IsMe() bool {
 	return r.User != nil && r.User.Username == r.Username
 }
 
+// Orders returns all orders that posts can be sorted by.
+func (r renderCtx) Orders() []smolboard.OrderBy {
+	return smolboard.AllOrders()
+}
+
+// OrderBy returns the current order, which is never empty.
+func (r renderCtx) OrderBy() smolboard.OrderBy {
+	if r.Order.IsZero() {
+		return smolboard.OrderNewest
+	}
+	return r.Order.By
+}
+
+// OrderValue returns the value of the order parameter, or an empty string if
+// the order is the default one.
+func (r renderCtx) OrderValue() string {
+	if r.Order.IsZero() {
+		return ""
+	}
+	return r.Order.String()
+}
+
 func (r renderCtx) AllowedTypes() string {
 	return strings.Join(r.Types, ",")
 }
@@ -97,6 +170,7 @@ func Mount(muxer render.Muxer) http.Handler {
 	mux := chi.NewMux()
 	mux.Get("/", muxer.M(pageRender))
 	mux.Post("/", muxer.M(uploader))
+	mux.Post("/similar", muxer.M(similarSearch))
 	return mux
 }
 
@@ -108,7 +182,37 @@ func pageRender(r *render.Request) (render.Render, error) {
 
 	var query = r.FormValue("q")
 
-	p, err := r.Session.PostSearch(query, pager.PageSize, page-1)
+	order, err := smolboard.ParseOrder(r.FormValue("o"))
+	if err != nil {
+		return render.Empty, err
+	}
+
+	// Random orders need a seed to stay the same across pages, so generate one
+	// and redirect to it.
+	if order.By == smolboard.OrderRandom && order.Seed == 0 {
+		order.Seed = time.Now().UnixNano()
+
+		v := r.URL.Query()
+		v.Set("o", order.String())
+		v.Del("p")
+		v.Del("from")
+		v.Del("before")
+		v.Del("after")
+
+		r.Redirect("/posts?"+v.Encode(), http.StatusSeeOther)
+		return render.Empty, nil
+	}
+
+	params, err := pager.SearchParams(r)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	params.Query = query
+	params.Order = order
+	params.Facets = true
+
+	p, err := r.Session.SearchPosts(params)
 	if err != nil {
 		return render.Empty, err
 	}
@@ -134,8 +238,23 @@ func pageRender(r *render.Request) (render.Render, error) {
 
 		Page:  page,
 		Query: query,
+		Order: order,
 
 		DefaultUploadPerm: defperm,
+		NearDuplicates:    popNearDuplicates(r),
+	}
+
+	// Show the tag's description if the query is only the tag. The query was
+	// already parsed by the server, so errors can be ignored.
+	if q, err := smolboard.ParsePostQuery(query); err == nil {
+		if tag, ok := q.Expr.(smolboard.QueryTag); ok {
+			renderCtx.Tag = string(tag)
+
+			d, err := r.Session.TagDescription(renderCtx.Tag)
+			if err == nil {
+				renderCtx.TagDescription = d.Description
+			}
+		}
 	}
 
 	// If we can upload, then we should get the supported MIME types for the
@@ -173,7 +292,7 @@ func uploader(r *render.Request) (render.Render, error) {
 	defer p.Body.Close()
 
 	// Shitty hack.
-	var posts []smolboard.Post
+	var posts []smolboard.UploadedPost
 
 	if err := json.NewDecoder(p.Body).Decode(&posts); err != nil {
 		return render.Empty, errors.Wrap(err, "Invalid JSON response from server")
@@ -185,6 +304,75 @@ func uploader(r *render.Request) (render.Render, error) {
 		r.SetWeakCookie("uploadperm", posts[0].Permission.StringInt())
 	}
 
+	// Carry the near-duplicates over to the gallery to warn about them.
+	var nearDups []string
+	for _, post := range posts {
+		for _, id := range post.NearDuplicates {
+			nearDups = append(nearDups, strconv.FormatInt(id, 10))
+		}
+	}
+
+	if len(nearDups) > 0 {
+		r.SetWeakCookie(nearDupsCookie, strings.Join(nearDups, "."))
+	}
+
 	r.Redirect("/posts", http.StatusSeeOther)
 	return render.Empty, nil
 }
+
+// popNearDuplicates returns the near-duplicates stored after uploading and
+// clears them.
+func popNearDuplicates(r *render.Request) []int64 {
+	v := r.CookieValue(nearDupsCookie)
+	if v == "" {
+		return nil
+	}
+
+	r.SetWeakCookie(nearDupsCookie, "")
+
+	var ids []int64
+
+	for _, str := range strings.Split(v, ".") {
+		if id, err := strconv.ParseInt(str, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// similarSearch searches for posts that look like the uploaded image.
+func similarSearch(r *render.Request) (render.Render, error) {
+	q, err := http.NewRequestWithContext(
+		r.Context(), "POST", r.Session.Endpoint("/search/image"), r.Body,
+	)
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to create request")
+	}
+
+	// Copy all headers, including Content-Type.
+	for k, v := range r.Header {
+		q.Header[k] = v
+	}
+
+	p, err := r.Session.Client.DoOnce(q)
+	if err != nil {
+		return render.Empty, err
+	}
+	defer p.Body.Close()
+
+	var similar []smolboard.SimilarPost
+
+	if err := json.NewDecoder(p.Body).Decode(&similar); err != nil {
+		return render.Empty, errors.Wrap(err, "Invalid JSON response from server")
+	}
+
+	return render.Render{
+		Title:       "Similar Posts",
+		Description: fmt.Sprintf("%d similar posts", len(similar)),
+		Body: similarTmpl.Render(similarCtx{
+			renderCtx: renderCtx{CommonCtx: r.CommonCtx},
+			Similar:   similar,
+		}),
+	}, nil
+}
@@ -0,0 +1,411 @@
+package pool
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/footer"
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/nav"
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/pager"
+	"github.com/diamondburned/smolboard/frontend/frontserver/internal/unblur"
+	"github.com/diamondburned/smolboard/frontend/frontserver/render"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	render.RegisterCSSFile("pages/pool/pool.css")
+}
+
+var components = map[string]render.Component{
+	"nav":    nav.Component,
+	"footer": footer.Component,
+}
+
+var functions = map[string]interface{}{
+	"isImage": func(ctype string) bool { return genericMIME(ctype) == "image" },
+	"isVideo": func(ctype string) bool { return genericMIME(ctype) == "video" },
+
+	"allPermissions": smolboard.AllPermissions,
+
+	"dec": func(i int) int { return i - 1 },
+	"inc": func(i int) int { return i + 1 },
+}
+
+var listTmpl = render.BuildPage("pools", render.Page{
+	Template:   "pages/pool/pools.html",
+	Components: components,
+	Functions:  functions,
+})
+
+var poolTmpl = render.BuildPage("pool", render.Page{
+	Template:   "pages/pool/pool.html",
+	Components: components,
+	Functions:  functions,
+})
+
+var viewerTmpl = render.BuildPage("pool-viewer", render.Page{
+	Template:   "pages/pool/viewer.html",
+	Components: components,
+	Functions:  functions,
+})
+
+// PageSize is the number of pools listed per page.
+const PageSize = pager.PageSize
+
+// MaxThumbSize is the maximum size of the posts' thumbnails in the pool.
+const MaxThumbSize = 200
+
+func genericMIME(mime string) string {
+	if parts := strings.Split(mime, "/"); len(parts) > 0 {
+		return parts[0]
+	}
+	return ""
+}
+
+type listCtx struct {
+	render.CommonCtx
+	smolboard.PoolList
+	User smolboard.UserPart
+	Page int // ?p=X, 1-indexed
+}
+
+// Pages returns the number of pages.
+func (r listCtx) Pages() int {
+	return (r.Total + PageSize - 1) / PageSize
+}
+
+// AllowedPerms returns the permissions that the user can create pools with.
+func (r listCtx) AllowedPerms() []smolboard.Permission {
+	return r.User.AllowedPermissions()
+}
+
+func (r listCtx) MaxNameLen() int        { return smolboard.MaxPoolNameLen }
+func (r listCtx) MaxDescriptionLen() int { return smolboard.MaxPoolDescriptionLen }
+
+type poolCtx struct {
+	render.CommonCtx
+	smolboard.PoolExtended
+	User smolboard.UserPart
+}
+
+// CanChange returns true if the user can change the pool. The backend still
+// checks that administrators are above the owner.
+func (r poolCtx) CanChange() bool {
+	if r.Owner != nil && *r.Owner == r.User.Username {
+		return true
+	}
+	return r.User.Permission >= smolboard.PermissionAdministrator
+}
+
+// AllowedPerms returns the permissions that the user can set on the pool.
+func (r poolCtx) AllowedPerms() []smolboard.Permission {
+	return r.User.AllowedPermissions()
+}
+
+func (r poolCtx) MaxNameLen() int        { return smolboard.MaxPoolNameLen }
+func (r poolCtx) MaxDescriptionLen() int { return smolboard.MaxPoolDescriptionLen }
+
+func (r poolCtx) SizeAttr(p smolboard.Post) template.HTMLAttr {
+	if p.Attributes.Height == 0 || p.Attributes.Width == 0 {
+		return ""
+	}
+
+	w, h := unblur.MaxSize(
+		p.Attributes.Width, p.Attributes.Height,
+		MaxThumbSize, MaxThumbSize,
+	)
+
+	return template.HTMLAttr(fmt.Sprintf(`width="%d" height="%d"`, w, h))
+}
+
+type viewerCtx struct {
+	render.CommonCtx
+	smolboard.PoolExtended
+	Index int
+}
+
+// Current returns the post being viewed.
+func (r viewerCtx) Current() smolboard.Post {
+	return r.Posts[r.Index]
+}
+
+// Prev returns the previous post in the pool, or nil if this is the first.
+func (r viewerCtx) Prev() *smolboard.Post {
+	if r.Index == 0 {
+		return nil
+	}
+	return &r.Posts[r.Index-1]
+}
+
+// Next returns the next post in the pool, or nil if this is the last.
+func (r viewerCtx) Next() *smolboard.Post {
+	if r.Index == len(r.Posts)-1 {
+		return nil
+	}
+	return &r.Posts[r.Index+1]
+}
+
+func Mount(muxer render.Muxer) http.Handler {
+	mux := chi.NewMux()
+	mux.Get("/", muxer.M(listRender))
+	mux.Post("/", muxer.M(createPool))
+
+	mux.Route("/{id}", func(mux chi.Router) {
+		mux.Get("/", muxer.M(poolRender))
+		mux.Post("/edit", muxer.M(editPool))
+		mux.Post("/delete", muxer.M(deletePool))
+		mux.Post("/add", muxer.M(addPost))
+		mux.Post("/remove", muxer.M(removePost))
+		mux.Post("/move", muxer.M(movePost))
+		mux.Get("/{postID}", muxer.M(viewerRender))
+	})
+
+	return mux
+}
+
+func me(r *render.Request) smolboard.UserPart {
+	// Try and get the current user, but create a dummy user if we can't.
+	u, err := r.Me()
+	if err != nil {
+		u = smolboard.UserPart{
+			Username: r.Username,
+		}
+	}
+	return u
+}
+
+func listRender(r *render.Request) (render.Render, error) {
+	page, err := pager.Page(r)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	l, err := r.Session.Pools(PageSize, page-1)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	return render.Render{
+		Title: "Pools",
+		Body: listTmpl.Render(listCtx{
+			CommonCtx: r.CommonCtx,
+			PoolList:  l,
+			User:      me(r),
+			Page:      page,
+		}),
+	}, nil
+}
+
+func createPool(r *render.Request) (render.Render, error) {
+	perm, err := strconv.Atoi(r.FormValue("p"))
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to parse permission")
+	}
+
+	p, err := r.Session.CreatePool(
+		r.FormValue("name"), r.FormValue("description"), smolboard.Permission(perm),
+	)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(fmt.Sprintf("/pools/%d", p.ID), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func poolRender(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	p, err := r.Session.Pool(i)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	return render.Render{
+		Title:       p.Name,
+		Description: fmt.Sprintf("Pool of %d posts.", len(p.Posts)),
+		Body: poolTmpl.Render(poolCtx{
+			CommonCtx:    r.CommonCtx,
+			PoolExtended: p,
+			User:         me(r),
+		}),
+	}, nil
+}
+
+func viewerRender(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	postID, err := strconv.ParseInt(r.Param("postID"), 10, 64)
+	if err != nil {
+		return render.Empty, smolboard.ErrPostNotInPool
+	}
+
+	p, err := r.Session.Pool(i)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	var index = p.Index(postID)
+	if index < 0 {
+		return render.Empty, smolboard.ErrPostNotInPool
+	}
+
+	return render.Render{
+		Title:       fmt.Sprintf("%s (%d/%d)", p.Name, index+1, len(p.Posts)),
+		Description: p.Description,
+		ImageURL:    r.Session.PostDirectPath(p.Posts[index]),
+		Body: viewerTmpl.Render(viewerCtx{
+			CommonCtx:    r.CommonCtx,
+			PoolExtended: p,
+			Index:        index,
+		}),
+	}, nil
+}
+
+func editPool(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	p, err := strconv.Atoi(r.FormValue("p"))
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to parse permission")
+	}
+
+	var (
+		name        = r.FormValue("name")
+		description = r.FormValue("description")
+		perm        = smolboard.Permission(p)
+	)
+
+	err = r.Session.EditPool(i, smolboard.PoolEdit{
+		Name:        &name,
+		Description: &description,
+		Permission:  &perm,
+	})
+	if err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(fmt.Sprintf("/pools/%d", i), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func deletePool(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	if err := r.Session.DeletePool(i); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect("/pools", http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+// postParam returns the post ID in the form.
+func postParam(r *render.Request) (int64, error) {
+	i, err := strconv.ParseInt(r.FormValue("post"), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to parse post ID")
+	}
+	return i, nil
+}
+
+func addPost(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	postID, err := postParam(r)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	if err := r.Session.AddPoolPost(i, postID); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func removePost(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	postID, err := postParam(r)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	if err := r.Session.RemovePoolPost(i, postID); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+// movePost moves the post one place earlier or later in the pool, depending on
+// whether "d" is -1 or 1.
+func movePost(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	postID, err := postParam(r)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	d, err := strconv.Atoi(r.FormValue("d"))
+	if err != nil || (d != -1 && d != 1) {
+		return render.Empty, errors.New("invalid direction")
+	}
+
+	// Reordering needs all posts, including blacklisted ones.
+	p, err := r.Session.PoolShowBlacklisted(i)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	var from = p.Index(postID)
+	if from < 0 {
+		return render.Empty, smolboard.ErrPostNotInPool
+	}
+
+	var ids = make([]int64, len(p.Posts))
+	for i, post := range p.Posts {
+		ids[i] = post.ID
+	}
+
+	if to := from + d; to >= 0 && to < len(ids) {
+		ids[from], ids[to] = ids[to], ids[from]
+
+		if err := r.Session.ReorderPool(i, ids); err != nil {
+			return render.Empty, err
+		}
+	}
+
+	r.Redirect(fmt.Sprintf("/pools/%d", i), http.StatusSeeOther)
+	return render.Empty, nil
+}
@@ -0,0 +1,107 @@
+package post
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/footer"
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/nav"
+	"github.com/diamondburned/smolboard/frontend/frontserver/render"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+var historyTmpl = render.BuildPage("post-history", render.Page{
+	Template: "pages/post/history.html",
+	Components: map[string]render.Component{
+		"nav":    nav.Component,
+		"footer": footer.Component,
+	},
+	Functions: map[string]interface{}{
+		"rfc3339": func(t time.Time) string { return t.Format(time.RFC3339Nano) },
+	},
+})
+
+type historyCtx struct {
+	render.CommonCtx
+	User   smolboard.UserPart
+	PostID int64
+	Edits  []smolboard.TagEdit
+	// ShowBlacklisted is true if the user chose to see the post anyway.
+	ShowBlacklisted bool
+}
+
+// PostPath returns the path back to the post, which keeps the blacklist
+// override.
+func (r historyCtx) PostPath() string {
+	var path = fmt.Sprintf("/posts/%d", r.PostID)
+	if r.ShowBlacklisted {
+		path += "?showblacklisted=1"
+	}
+	return path
+}
+
+func (r historyCtx) CanRevert() bool {
+	return r.User.Permission >= smolboard.PermissionAdministrator
+}
+
+func historyRender(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	showBlacklisted, _ := strconv.ParseBool(r.FormValue("showblacklisted"))
+
+	var e []smolboard.TagEdit
+
+	if showBlacklisted {
+		e, err = r.Session.PostHistoryShowBlacklisted(i)
+	} else {
+		e, err = r.Session.PostHistory(i)
+	}
+
+	if err != nil {
+		return render.Empty, err
+	}
+
+	u, err := r.Me()
+	if err != nil {
+		u = smolboard.UserPart{
+			Username: r.Username,
+		}
+	}
+
+	return render.Render{
+		Title: fmt.Sprintf("Post %d History", i),
+		Body: historyTmpl.Render(historyCtx{
+			CommonCtx: r.CommonCtx,
+			User:      u,
+			PostID:    i,
+			Edits:     e,
+
+			ShowBlacklisted: showBlacklisted,
+		}),
+	}, nil
+}
+
+func revertPost(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, r.FormValue("t"))
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to parse time")
+	}
+
+	if _, err := r.Session.RevertPostTags(i, t); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(fmt.Sprintf("/posts/%d", i), http.StatusSeeOther)
+	return render.Empty, nil
+}
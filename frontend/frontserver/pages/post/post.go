@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/diamondburned/smolboard/client"
 	"github.com/diamondburned/smolboard/frontend/frontserver/components/footer"
 	"github.com/diamondburned/smolboard/frontend/frontserver/components/nav"
 	"github.com/diamondburned/smolboard/frontend/frontserver/render"
@@ -30,6 +31,9 @@ var tmpl = render.BuildPage("post", render.Page{
 		"isImage": func(ctype string) bool { return genericMIME(ctype) == "image" },
 		"isVideo": func(ctype string) bool { return genericMIME(ctype) == "video" },
 
+		"dec": func(i int) int { return i - 1 },
+		"inc": func(i int) int { return i + 1 },
+
 		"allPermissions": func() []smolboard.Permission {
 			return smolboard.AllPermissions()
 		},
@@ -49,8 +53,96 @@ type renderCtx struct {
 	Post          smolboard.PostExtended
 	Poster        string
 	CanChangePost bool
+
+	// Blacklisted is true if the post is hidden by the user's tag blacklist.
+	// Post is empty if this is true.
+	Blacklisted bool
+	// ShowBlacklisted is true if the user chose to see the post anyway.
+	ShowBlacklisted bool
+
+	Comments    smolboard.CommentList
+	CommentPage int // ?cp=X, 1-indexed
+}
+
+// CommentPageSize is the number of comments shown per page.
+const CommentPageSize = 25
+
+// CommentPages returns the number of comment pages.
+func (r renderCtx) CommentPages() int {
+	return (r.Comments.Total + CommentPageSize - 1) / CommentPageSize
+}
+
+// CommentPagePath returns the path to the given page of comments, which keeps
+// the blacklist override.
+func (r renderCtx) CommentPagePath(page int) string {
+	var path = fmt.Sprintf("/posts/%d?cp=%d", r.Post.ID, page)
+	if r.ShowBlacklisted {
+		path += "&showblacklisted=1"
+	}
+	return path + "#comments"
+}
+
+// IsUser returns true if the user is at least a user, who can comment on,
+// favorite and vote on posts.
+func (r renderCtx) IsUser() bool {
+	return r.User.Permission >= smolboard.PermissionUser
+}
+
+// CanDeleteComment returns true if the user can delete the comment. The
+// backend still checks that administrators are above the commenter.
+func (r renderCtx) CanDeleteComment(c smolboard.Comment) bool {
+	if c.Commenter != nil && *c.Commenter == r.User.Username {
+		return true
+	}
+	return r.User.Permission >= smolboard.PermissionAdministrator
+}
+
+// DirectPath returns the path to the post's content, which keeps the blacklist
+// override.
+func (r renderCtx) DirectPath(p smolboard.Post) string {
+	return r.withOverride(r.Session.PostDirectPath(p))
 }
 
+// ThumbPath returns the path to the post's thumbnail, which keeps the
+// blacklist override.
+func (r renderCtx) ThumbPath(p smolboard.Post) string {
+	return r.withOverride(r.Session.PostThumbPath(p))
+}
+
+// HistoryPath returns the path to the post's tag history, which keeps the
+// blacklist override.
+func (r renderCtx) HistoryPath() string {
+	return r.withOverride(fmt.Sprintf("/posts/%d/history", r.Post.ID))
+}
+
+func (r renderCtx) withOverride(path string) string {
+	if r.ShowBlacklisted {
+		path += "?showblacklisted=1"
+	}
+	return path
+}
+
+// MaxTitleLen, MaxDescriptionLen and MaxSourceLen are the limits of the post's
+// text fields for the editor.
+func (r renderCtx) MaxTitleLen() int       { return smolboard.MaxPostTitleLen }
+func (r renderCtx) MaxDescriptionLen() int { return smolboard.MaxPostDescriptionLen }
+func (r renderCtx) MaxSourceLen() int      { return smolboard.MaxPostSourceLen }
+
+// UpvoteValue and DownvoteValue return the votes to send when the up or down
+// buttons are pressed, which removes the vote if it's already the same.
+func (r renderCtx) UpvoteValue() smolboard.Vote   { return r.toggleVote(smolboard.VoteUp) }
+func (r renderCtx) DownvoteValue() smolboard.Vote { return r.toggleVote(smolboard.VoteDown) }
+
+func (r renderCtx) toggleVote(v smolboard.Vote) smolboard.Vote {
+	if r.Post.Vote == v {
+		return smolboard.VoteNone
+	}
+	return v
+}
+
+// MaxCommentLen is the limit of a comment for the comment box.
+func (r renderCtx) MaxCommentLen() int { return smolboard.MaxCommentLen }
+
 func (r renderCtx) AllowedSetPerms() []smolboard.Permission {
 	var allPerms = smolboard.AllPermissions()
 
@@ -83,8 +175,16 @@ func Mount(muxer render.Muxer) http.Handler {
 	mux.Get("/", muxer.M(pageRender))
 	mux.Post("/delete", muxer.M(deletePost))
 	mux.Post("/permission", muxer.M(changePermission))
+	mux.Post("/edit", muxer.M(editPost))
+	mux.Post("/favorite", muxer.M(favoritePost))
+	mux.Post("/unfavorite", muxer.M(unfavoritePost))
+	mux.Post("/vote", muxer.M(votePost))
+	mux.Post("/comment", muxer.M(addComment))
+	mux.Post("/uncomment", muxer.M(deleteComment))
 	mux.Post("/tag", muxer.M(tagPost))
 	mux.Post("/untag", muxer.M(untagPost))
+	mux.Get("/history", muxer.M(historyRender))
+	mux.Post("/revert", muxer.M(revertPost))
 	return mux
 }
 
@@ -94,8 +194,28 @@ func pageRender(r *render.Request) (render.Render, error) {
 		return render.Empty, err
 	}
 
-	p, err := r.Session.Post(i)
+	showBlacklisted, _ := strconv.ParseBool(r.FormValue("showblacklisted"))
+
+	var p smolboard.PostExtended
+
+	if showBlacklisted {
+		p, err = r.Session.PostShowBlacklisted(i)
+	} else {
+		p, err = r.Session.Post(i)
+	}
+
 	if err != nil {
+		// Let the user choose to see the post instead of erroring out.
+		if client.ErrIs(err, smolboard.ErrPostBlacklisted) {
+			return render.Render{
+				Title: "Blacklisted Post",
+				Body: tmpl.Render(renderCtx{
+					CommonCtx:   r.CommonCtx,
+					Blacklisted: true,
+				}),
+			}, nil
+		}
+
 		return render.Empty, err
 	}
 
@@ -112,28 +232,62 @@ func pageRender(r *render.Request) (render.Render, error) {
 		poster = *p.Poster
 	}
 
+	var commentPage = 1
+	if str := r.FormValue("cp"); str != "" {
+		commentPage, err = strconv.Atoi(str)
+		if err != nil || commentPage < 1 {
+			return render.Empty, errors.New("invalid comment page")
+		}
+	}
+
+	var comments smolboard.CommentList
+
+	if showBlacklisted {
+		comments, err = r.Session.CommentsShowBlacklisted(i, CommentPageSize, commentPage-1)
+	} else {
+		comments, err = r.Session.Comments(i, CommentPageSize, commentPage-1)
+	}
+
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to get comments")
+	}
+
 	var renderCtx = renderCtx{
 		CommonCtx:     r.CommonCtx,
 		User:          u,
 		Post:          p,
 		Poster:        poster,
 		CanChangePost: u.CanChangePost(p.Post) == nil,
+
+		ShowBlacklisted: showBlacklisted,
+
+		Comments:    comments,
+		CommentPage: commentPage,
 	}
 
 	description := strings.Builder{}
 	description.Grow(128)
 
-	for i, tag := range p.Tags {
-		if description.WriteString(tag.TagName); description.Len() > 128 {
-			break
-		}
-		if i < len(p.Tags)-1 {
-			description.WriteString(", ")
+	if p.Description != "" {
+		description.WriteString(p.Description)
+	} else {
+		for i, tag := range p.Tags {
+			if description.WriteString(tag.TagName); description.Len() > 128 {
+				break
+			}
+			if i < len(p.Tags)-1 {
+				description.WriteString(", ")
+			}
 		}
 	}
 
+	var title = poster
+	if p.Title != "" {
+		title = p.Title
+	}
+
 	return render.Render{
-		Title:       poster,
+		Title:       title,
 		Description: ellipsize(description.String()),
 		ImageURL:    r.Session.PostDirectPath(p.Post),
 		Body:        tmpl.Render(renderCtx),
@@ -173,6 +327,116 @@ func changePermission(r *render.Request) (render.Render, error) {
 	return render.Empty, nil
 }
 
+func editPost(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	var (
+		title       = r.FormValue("title")
+		description = r.FormValue("description")
+		source      = r.FormValue("source")
+		parent      int64
+	)
+
+	// An empty parent removes the post's parent.
+	if str := r.FormValue("parent"); str != "" {
+		parent, err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return render.Empty, errors.Wrap(err, "Failed to parse parent ID")
+		}
+	}
+
+	err = r.Session.EditPost(i, smolboard.PostEdit{
+		Title:       &title,
+		Description: &description,
+		Source:      &source,
+		Parent:      &parent,
+	})
+	if err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(fmt.Sprintf("/posts/%d", i), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func favoritePost(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	if err := r.Session.FavoritePost(i); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func unfavoritePost(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	if err := r.Session.UnfavoritePost(i); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func votePost(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	v, err := strconv.Atoi(r.FormValue("v"))
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to parse vote")
+	}
+
+	if err := r.Session.VotePost(i, smolboard.Vote(v)); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func addComment(r *render.Request) (render.Render, error) {
+	i, err := r.IDParam()
+	if err != nil {
+		return render.Empty, err
+	}
+
+	if _, err := r.Session.AddComment(i, r.FormValue("body")); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func deleteComment(r *render.Request) (render.Render, error) {
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to parse comment ID")
+	}
+
+	if err := r.Session.DeleteComment(id); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
 func tagPost(r *render.Request) (render.Render, error) {
 	i, err := r.IDParam()
 	if err != nil {
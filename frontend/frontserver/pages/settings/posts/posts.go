@@ -236,9 +236,15 @@ func renderPage(r *render.Request) (render.Render, error) {
 		return render.Empty, err
 	}
 
+	params, err := pager.SearchParams(r)
+	if err != nil {
+		return render.Empty, err
+	}
+
 	var query = r.FormValue("q")
+	params.Query = query
 
-	p, err := r.Session.PostSearch(query, pager.PageSize, page-1)
+	p, err := r.Session.SearchPosts(params)
 	if err != nil {
 		return render.Empty, err
 	}
@@ -363,32 +369,39 @@ func processPOST(r *render.Request) (render.Render, error) {
 		return render.Empty, resetSettings(r)
 	}
 
-	var errors = newErrStack()
+	var batch = smolboard.PostBatch{
+		PostIDs: make([]int64, 0, len(s.Selections)),
+	}
+
+	for postID := range s.Selections {
+		batch.PostIDs = append(batch.PostIDs, postID)
+	}
 
 	if s.Permission > -1 {
-		for postID := range s.Selections {
-			if err := r.Session.SetPostPermission(postID, s.Permission); err != nil {
-				errors.JobErrorf(postID, err, "Failed to set post ID %d's permission", postID)
-			}
-		}
+		batch.Permission = &s.Permission
 	}
 
 	for tag := range s.Tags {
-		var action = r.Session.TagPost
 		if strings.HasPrefix(tag, "-") {
-			tag = tag[1:]
-			action = r.Session.UntagPost
+			batch.RemoveTags = append(batch.RemoveTags, tag[1:])
+		} else {
+			batch.AddTags = append(batch.AddTags, tag)
 		}
+	}
 
-		for postID := range s.Selections {
-			if err := action(postID, tag); err != nil {
-				// Ignore these errors.
-				if client.ErrIs(err, smolboard.ErrTagAlreadyAdded, smolboard.ErrPostNotFound) {
-					continue
-				}
+	results, err := r.Session.EditPosts(batch)
+	if err != nil {
+		return render.Empty, err
+	}
 
-				errors.JobErrorf(postID, err, "Failed to set post ID %d's tag", postID)
-			}
+	var errors = newErrStack()
+
+	for _, result := range results {
+		if result.Error != "" {
+			errors.JobErrorf(
+				result.PostID, batchError(result.Error),
+				"Failed to edit post ID %d", result.PostID,
+			)
 		}
 	}
 
@@ -399,6 +412,13 @@ func processPOST(r *render.Request) (render.Render, error) {
 	return render.Empty, resetSettings(r)
 }
 
+// batchError is the error message of a post that failed in a batch edit.
+type batchError string
+
+func (err batchError) Error() string {
+	return string(err)
+}
+
 func deletePostsPOST(r *render.Request) (render.Render, error) {
 	s, err := UnmarshalState(r)
 	if err != nil {
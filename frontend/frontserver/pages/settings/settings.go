@@ -10,6 +10,7 @@ import (
 	"github.com/diamondburned/smolboard/frontend/frontserver/components/footer"
 	"github.com/diamondburned/smolboard/frontend/frontserver/components/nav"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/settings/posts"
+	"github.com/diamondburned/smolboard/frontend/frontserver/pages/settings/tags"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/settings/tokens"
 	"github.com/diamondburned/smolboard/frontend/frontserver/pages/settings/users"
 	"github.com/diamondburned/smolboard/frontend/frontserver/render"
@@ -52,8 +53,9 @@ var tmpl = render.BuildPage("cpanel", render.Page{
 // Normal user stuff.
 type renderCtx struct {
 	render.CommonCtx
-	Current  smolboard.UserPart
-	Sessions []smolboard.Session
+	Current   smolboard.UserPart
+	Sessions  []smolboard.Session
+	Blacklist []string
 }
 
 func (r renderCtx) IsAdmin() bool {
@@ -67,10 +69,13 @@ func Mount(muxer render.Muxer) http.Handler {
 
 	mux.Mount("/tokens", tokens.Mount(muxer))
 	mux.Mount("/posts", posts.Mount(muxer))
+	mux.Mount("/tags", tags.Mount(muxer))
 	mux.Route("/users", func(mux chi.Router) {
 		mux.Route("/@me", func(mux chi.Router) {
 			mux.Post("/delete", muxer.M(deleteUser))
 			mux.Post("/change-password", muxer.M(changePassword))
+			mux.Post("/blacklist/add", muxer.M(addBlacklist))
+			mux.Post("/blacklist/remove", muxer.M(removeBlacklist))
 		})
 
 		mux.Mount("/", users.Mount(muxer))
@@ -90,6 +95,11 @@ func userPanel(r *render.Request) (render.Render, error) {
 		return render.Empty, errors.Wrap(err, "Failed to get sessions")
 	}
 
+	b, err := r.Session.Blacklist()
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to get blacklist")
+	}
+
 	// Put the current session first.
 	sort.SliceStable(s, func(i, j int) bool {
 		return s[i].AuthToken != ""
@@ -101,6 +111,7 @@ func userPanel(r *render.Request) (render.Render, error) {
 			CommonCtx: r.CommonCtx,
 			Current:   u,
 			Sessions:  s,
+			Blacklist: b,
 		}),
 	}, nil
 }
@@ -126,6 +137,24 @@ func changePassword(r *render.Request) (render.Render, error) {
 	return render.Empty, nil
 }
 
+func addBlacklist(r *render.Request) (render.Render, error) {
+	if _, err := r.Session.AddBlacklist(r.FormValue("tag")); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func removeBlacklist(r *render.Request) (render.Render, error) {
+	if _, err := r.Session.RemoveBlacklist(r.FormValue("tag")); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
 func deleteSession(r *render.Request) (render.Render, error) {
 	i, err := strconv.ParseInt(chi.URLParam(r.Request, "sessionID"), 10, 64)
 	if err != nil {
@@ -0,0 +1,112 @@
+package tags
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/footer"
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/nav"
+	"github.com/diamondburned/smolboard/frontend/frontserver/render"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	render.RegisterCSSFile("pages/settings/tags/tags.css")
+}
+
+var tmpl = render.BuildPage("cpanel", render.Page{
+	Template: "pages/settings/tags/tags.html",
+	Components: map[string]render.Component{
+		"nav":    nav.Component,
+		"footer": footer.Component,
+	},
+	Functions: map[string]interface{}{
+		"allPermissions": smolboard.AllPermissions,
+		"escapeTag":      smolboard.EscapeTag,
+	},
+})
+
+type renderCtx struct {
+	render.CommonCtx
+	Tags       []smolboard.PostTag
+	Query      string // ?q=X
+	Restricted []smolboard.RestrictedTag
+}
+
+func Mount(muxer render.Muxer) http.Handler {
+	mux := chi.NewMux()
+	mux.Get("/", muxer.M(renderPage))
+	// The tag is in the form instead of the path, as tags may have slashes.
+	mux.Post("/rename", muxer.M(renameTag))
+	mux.Post("/merge", muxer.M(mergeTags))
+	mux.Post("/restrict", muxer.M(restrictTag))
+	mux.Post("/unrestrict", muxer.M(unrestrictTag))
+	return mux
+}
+
+func renderPage(r *render.Request) (render.Render, error) {
+	var query = r.FormValue("q")
+
+	t, err := r.Session.SearchTags(query)
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to search tags")
+	}
+
+	rt, err := r.Session.RestrictedTags()
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to get restricted tags")
+	}
+
+	return render.Render{
+		Title: "Tags",
+		Body: tmpl.Render(renderCtx{
+			CommonCtx:  r.CommonCtx,
+			Tags:       t,
+			Query:      query,
+			Restricted: rt,
+		}),
+	}, nil
+}
+
+func renameTag(r *render.Request) (render.Render, error) {
+	if err := r.Session.RenameTag(r.FormValue("tag"), r.FormValue("to")); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func mergeTags(r *render.Request) (render.Render, error) {
+	if err := r.Session.MergeTags(r.FormValue("tag"), r.FormValue("to")); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func restrictTag(r *render.Request) (render.Render, error) {
+	p, err := strconv.Atoi(r.FormValue("p"))
+	if err != nil {
+		return render.Empty, errors.Wrap(err, "Failed to parse permission")
+	}
+
+	if err := r.Session.SetRestrictedTag(r.FormValue("tag"), smolboard.Permission(p)); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
+
+func unrestrictTag(r *render.Request) (render.Render, error) {
+	if err := r.Session.DeleteRestrictedTag(r.FormValue("tag")); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect(r.Referer(), http.StatusSeeOther)
+	return render.Empty, nil
+}
@@ -0,0 +1,112 @@
+package tag
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/footer"
+	"github.com/diamondburned/smolboard/frontend/frontserver/components/nav"
+	"github.com/diamondburned/smolboard/frontend/frontserver/internal/markdown"
+	"github.com/diamondburned/smolboard/frontend/frontserver/render"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-chi/chi"
+)
+
+func init() {
+	render.RegisterCSSFile("pages/tag/tag.css")
+}
+
+var tmpl = render.BuildPage("tag", render.Page{
+	Template: "pages/tag/tag.html",
+	Components: map[string]render.Component{
+		"nav":    nav.Component,
+		"footer": footer.Component,
+	},
+	Functions: map[string]interface{}{
+		"markdown":  markdown.Render,
+		"escapeTag": smolboard.EscapeTag,
+	},
+})
+
+type renderCtx struct {
+	render.CommonCtx
+	User      smolboard.UserPart
+	Tag       string
+	Revisions []smolboard.TagRevision
+}
+
+// Description returns the current description, or an empty string if the tag
+// has none.
+func (r renderCtx) Description() string {
+	if len(r.Revisions) == 0 {
+		return ""
+	}
+	return r.Revisions[0].Description
+}
+
+func (r renderCtx) CanEdit() bool {
+	return r.User.Permission >= smolboard.PermissionTrusted
+}
+
+func Mount(muxer render.Muxer) http.Handler {
+	mux := chi.NewMux()
+	mux.Get("/", muxer.M(pageRender))
+	mux.Post("/", muxer.M(setDescription))
+	return mux
+}
+
+// tagParam returns the tag name in the URL. The path is escaped if the tag has
+// slashes.
+func tagParam(r *render.Request) string {
+	var name = chi.URLParam(r.Request, "name")
+
+	if r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+	}
+
+	return name
+}
+
+func pageRender(r *render.Request) (render.Render, error) {
+	var tag = tagParam(r)
+
+	revs, err := r.Session.TagRevisions(tag)
+	if err != nil {
+		return render.Empty, err
+	}
+
+	// Try and get the current user, but create a dummy user if we can't.
+	u, err := r.Me()
+	if err != nil {
+		u = smolboard.UserPart{
+			Username: r.Username,
+		}
+	}
+
+	var renderCtx = renderCtx{
+		CommonCtx: r.CommonCtx,
+		User:      u,
+		Tag:       tag,
+		Revisions: revs,
+	}
+
+	return render.Render{
+		Title:       tag,
+		Description: fmt.Sprintf("Wiki page of the tag %s.", tag),
+		Body:        tmpl.Render(renderCtx),
+	}, nil
+}
+
+func setDescription(r *render.Request) (render.Render, error) {
+	var tag = tagParam(r)
+
+	if _, err := r.Session.SetTagDescription(tag, r.FormValue("d")); err != nil {
+		return render.Empty, err
+	}
+
+	r.Redirect("/tags/"+url.PathEscape(tag), http.StatusSeeOther)
+	return render.Empty, nil
+}
@@ -0,0 +1,125 @@
+// autocomplete.js fills the datalists of inputs with a data-autocomplete
+// attribute with tags from the API as the user types. Inputs still work as
+// plain text fields without it.
+//
+// data-autocomplete="tag" completes the whole value as a single tag, while
+// data-autocomplete="query" completes the last word of a search query.
+(function() {
+	"use strict";
+
+	var delay = 150;
+
+	// needsQuotes mirrors smolboard.EscapeTag, except that it also quotes every
+	// tag with a colon so it's never taken for a qualifier.
+	function needsQuotes(name) {
+		return /[\s'"\\:]/.test(name) ||
+			/^[(\-@]/.test(name) ||
+			/^(OR|AND|NOT)$/.test(name) ||
+			name.indexOf("order:") === 0;
+	}
+
+	function escapeTag(name) {
+		if (!needsQuotes(name)) {
+			return name;
+		}
+		return "'" + name.replace(/\\/g, "\\\\").replace(/'/g, "\\'") + "'";
+	}
+
+	// splitQuery splits the query into everything before the last word and the
+	// last word itself, keeping the negation and group prefixes of the word.
+	function splitQuery(q) {
+		var i = Math.max(q.lastIndexOf(" "), q.lastIndexOf("\t")) + 1;
+		var prefix = q.slice(0, i);
+		var word = q.slice(i);
+
+		var trimmed = word.replace(/^[-(]+/, "");
+		prefix += word.slice(0, word.length - trimmed.length);
+
+		return { prefix: prefix, word: trimmed };
+	}
+
+	function bind(input) {
+		var list = input.list;
+		if (!list) {
+			return;
+		}
+
+		var mode = input.getAttribute("data-autocomplete");
+		var timeout = null;
+		var last = null;
+
+		function fill(prefix, tags) {
+			while (list.firstChild) {
+				list.removeChild(list.firstChild);
+			}
+
+			tags.forEach(function(tag) {
+				var option = document.createElement("option");
+
+				if (mode === "query") {
+					option.value = prefix + escapeTag(tag.tag_name);
+				} else {
+					option.value = tag.tag_name;
+					option.textContent = tag.count + " posts";
+				}
+
+				list.appendChild(option);
+			});
+		}
+
+		function update() {
+			var prefix = "";
+			var word = input.value.trim();
+
+			if (mode === "query") {
+				var split = splitQuery(input.value);
+				prefix = split.prefix;
+				word = split.word;
+
+				// Don't complete users, qualifiers and quoted tags.
+				if (word === "" || word[0] === "@" || /[:'"\\]/.test(word)) {
+					last = null;
+					fill(prefix, []);
+					return;
+				}
+			}
+
+			// Options carry the prefix, so they're stale if either changes.
+			var key = prefix + "\n" + word;
+			if (key === last) {
+				return;
+			}
+			last = key;
+
+			fetch("/api/v1/tags?q=" + encodeURIComponent(word), {
+				credentials: "same-origin",
+			})
+				.then(function(r) { return r.ok ? r.json() : []; })
+				.then(function(tags) {
+					// Drop responses that came in after the input changed.
+					if (key !== last) {
+						return;
+					}
+
+					fill(prefix, (tags || []).filter(function(tag) {
+						return tag.tag_name !== word;
+					}));
+				})
+				.catch(function() {});
+		}
+
+		input.addEventListener("input", function() {
+			clearTimeout(timeout);
+			timeout = setTimeout(update, delay);
+		});
+
+		input.addEventListener("focus", update);
+	}
+
+	document.addEventListener("DOMContentLoaded", function() {
+		var inputs = document.querySelectorAll("input[data-autocomplete]");
+		for (var i = 0; i < inputs.length; i++) {
+			bind(inputs[i]);
+		}
+	});
+})();
@@ -20,7 +20,6 @@ require (
 	github.com/gorilla/schema v1.1.0
 	github.com/jmoiron/sqlx v1.3.3
 	github.com/kr/pretty v0.1.0 // indirect
-	github.com/mattn/go-shellwords v1.0.10
 	github.com/mileusna/useragent v1.0.1
 	github.com/pelletier/go-toml v1.8.0
 	github.com/peterbourgon/diskv v2.0.1+incompatible
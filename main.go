@@ -67,6 +67,8 @@ func init() {
 		stderrlnf("Usage: %s [subcommand] [flags...]", filepath.Base(os.Args[0]))
 		stderrlnf("Subcommands:")
 		stderrlnf("  create-owner   Initialize a new owner user once")
+		stderrlnf("  rebuild-tags   Recount all tags in the database")
+		stderrlnf("  hash-posts     Hash the files of posts uploaded without hashes")
 		stderrlnf("  serve          Run the HTTP server")
 		stderrlnf("Flags:")
 		pflag.PrintDefaults()
@@ -124,6 +126,19 @@ func main() {
 			log.Fatalln(err)
 		}
 
+	case "rebuild-tags":
+		if err := server.RebuildTags(cfg.Config); err != nil {
+			log.Fatalln("Failed to rebuild tags:", err)
+		}
+
+	case "hash-posts":
+		n, err := server.HashPosts(cfg.Config)
+		if err != nil {
+			log.Fatalln("Failed to hash posts:", err)
+		}
+
+		log.Printf("Hashed %d posts.", n)
+
 	case "serve":
 		fallthrough
 	default:
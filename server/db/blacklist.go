@@ -0,0 +1,139 @@
+package db
+
+import (
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+// sqlBlacklisted returns the SQL condition for the post with the given ID
+// expression having a tag in the user's blacklist. The username is the only
+// argument.
+func sqlBlacklisted(postID string) string {
+	return `EXISTS (
+		SELECT 1 FROM posttags
+		JOIN   blacklists ON blacklists.tagname = posttags.tagname
+		WHERE  posttags.postid = ` + postID + ` AND blacklists.username = ?)`
+}
+
+// blacklisted returns true if the blacklist applies to the current user.
+func (d *Transaction) blacklisted() bool {
+	return !d.ShowBlacklisted && d.Session.Username != ""
+}
+
+// checkBlacklist returns ErrPostBlacklisted if the post has a tag in the
+// current user's blacklist. The user's own posts are never blacklisted.
+func (d *Transaction) checkBlacklist(post *smolboard.Post) error {
+	if !d.blacklisted() || post.Poster != nil && *post.Poster == d.Session.Username {
+		return nil
+	}
+
+	var blacklisted bool
+
+	err := d.QueryRow("SELECT "+sqlBlacklisted("?"), post.ID, d.Session.Username).
+		Scan(&blacklisted)
+	if err != nil {
+		return errors.Wrap(err, "Failed to check blacklist")
+	}
+
+	if blacklisted {
+		return smolboard.ErrPostBlacklisted
+	}
+
+	return nil
+}
+
+// Blacklist returns the tags in the current user's blacklist.
+func (d *Transaction) Blacklist() ([]string, error) {
+	r, err := d.Query(
+		"SELECT tagname FROM blacklists WHERE username = ? ORDER BY tagname ASC",
+		d.Session.Username,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query blacklist")
+	}
+
+	defer r.Close()
+
+	var tags = []string{}
+
+	for r.Next() {
+		var tag string
+
+		if err := r.Scan(&tag); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan blacklisted tag")
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// AddBlacklist adds the tag into the current user's blacklist. Aliases are
+// resolved to their canonical tags, since posts are never tagged with them.
+func (d *Transaction) AddBlacklist(tag string) error {
+	if err := validTagName(tag); err != nil {
+		return err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionUser, true); err != nil {
+		return err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	var count int
+
+	err = d.QueryRow("SELECT COUNT(1) FROM blacklists WHERE username = ?", d.Session.Username).
+		Scan(&count)
+	if err != nil {
+		return errors.Wrap(err, "Failed to count blacklisted tags")
+	}
+
+	if count >= smolboard.MaxBlacklistLen {
+		return smolboard.ErrBlacklistFull
+	}
+
+	if _, err := d.Exec("INSERT INTO blacklists VALUES (?, ?)", d.Session.Username, tag); err != nil {
+		if errIsConstraint(err) {
+			return smolboard.ErrTagAlreadyBlacklisted
+		}
+		return errors.Wrap(err, "Failed to insert blacklisted tag")
+	}
+
+	return nil
+}
+
+// RemoveBlacklist removes the tag from the current user's blacklist.
+func (d *Transaction) RemoveBlacklist(tag string) error {
+	if err := validTagName(tag); err != nil {
+		return err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	r, err := d.Exec(
+		"DELETE FROM blacklists WHERE username = ? AND tagname = ?",
+		d.Session.Username, tag,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete blacklisted tag")
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get rows affected")
+	}
+
+	if count == 0 {
+		return smolboard.ErrTagNotBlacklisted
+	}
+
+	return nil
+}
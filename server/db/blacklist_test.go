@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-test/deep"
+)
+
+func TestBlacklist(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	var cat, dog, catdog, own int64
+
+	expectPosts := func(t *testing.T, tx *Transaction, ids ...int64) {
+		t.Helper()
+
+		r, err := tx.Posts(100, 0)
+		if err != nil {
+			t.Fatal("Failed to get posts:", err)
+		}
+
+		var got = make([]int64, len(r.Posts))
+		for i, post := range r.Posts {
+			got[i] = post.ID
+		}
+
+		if eq := deep.Equal(got, ids); eq != nil {
+			t.Fatal("Unexpected posts:", eq)
+		}
+
+		if r.Total != len(ids) {
+			t.Fatal("Unexpected total:", r.Total)
+		}
+	}
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		cat = testNewTaggedPost(t, tx, "cat")
+		dog = testNewTaggedPost(t, tx, "dog")
+		catdog = testNewTaggedPost(t, tx, "cat", "dog")
+	})
+
+	t.Run("Hidden", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		own = testNewTaggedPost(t, tx, "cat")
+
+		if err := tx.AddBlacklist("CAT"); err != nil {
+			t.Fatal("Failed to blacklist tag:", err)
+		}
+
+		if err := tx.AddBlacklist("cat"); err != smolboard.ErrTagAlreadyBlacklisted {
+			t.Fatal("Unexpected error blacklisting tag twice:", err)
+		}
+
+		if err := tx.RemoveBlacklist("dog"); err != smolboard.ErrTagNotBlacklisted {
+			t.Fatal("Unexpected error removing missing tag:", err)
+		}
+
+		// The user's own post is never hidden.
+		expectPosts(t, tx, own, dog)
+
+		for _, id := range []int64{cat, catdog} {
+			if _, err := tx.Post(id); err != smolboard.ErrPostBlacklisted {
+				t.Fatal("Unexpected error getting blacklisted post:", err)
+			}
+			if _, err := tx.PostQuickGet(id); err != smolboard.ErrPostBlacklisted {
+				t.Fatal("Unexpected error quick getting blacklisted post:", err)
+			}
+		}
+
+		if _, err := tx.Post(own); err != nil {
+			t.Fatal("Failed to get own post:", err)
+		}
+
+		tx.ShowBlacklisted = true
+
+		expectPosts(t, tx, own, catdog, dog, cat)
+
+		if _, err := tx.Post(cat); err != nil {
+			t.Fatal("Failed to get overridden post:", err)
+		}
+
+		tx.ShowBlacklisted = false
+
+		if err := tx.RemoveBlacklist("cat"); err != nil {
+			t.Fatal("Failed to remove blacklisted tag:", err)
+		}
+
+		expectPosts(t, tx, own, catdog, dog, cat)
+
+		if err := tx.AddBlacklist("dog"); err != nil {
+			t.Fatal("Failed to blacklist tag:", err)
+		}
+	})
+
+	t.Run("Guest", func(t *testing.T) {
+		err := d.AcquireGuest(context.TODO(), func(tx *Transaction) error {
+			return tx.AddBlacklist("dog")
+		})
+		if err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error blacklisting as guest:", err)
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		if err := tx.RenameTag("dog", "inu"); err != nil {
+			t.Fatal("Failed to rename tag:", err)
+		}
+	})
+
+	tx := testBeginTx(t, d, user.AuthToken)
+
+	b, err := tx.Blacklist()
+	if err != nil {
+		t.Fatal("Failed to get blacklist:", err)
+	}
+
+	if eq := deep.Equal(b, []string{"inu"}); eq != nil {
+		t.Fatal("Unexpected blacklist after rename:", eq)
+	}
+
+	expectPosts(t, tx, own, cat)
+}
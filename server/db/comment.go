@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+// Comments returns a page of the post's comments, oldest first.
+func (d *Transaction) Comments(postID int64, count, page uint) (smolboard.CommentList, error) {
+	if count > 100 {
+		return smolboard.NoComments, smolboard.ErrPageCountLimit
+	}
+
+	// Make sure the user can see the post.
+	if _, err := d.PostQuickGet(postID); err != nil {
+		return smolboard.NoComments, err
+	}
+
+	var list = smolboard.CommentList{
+		Comments: make([]smolboard.Comment, 0, count),
+	}
+
+	r := d.QueryRow("SELECT COUNT(1) FROM comments WHERE postid = ?", postID)
+
+	if err := r.Scan(&list.Total); err != nil {
+		return smolboard.NoComments, errors.Wrap(err, "Failed to scan total")
+	}
+
+	q, err := d.Queryx(
+		"SELECT * FROM comments WHERE postid = ? ORDER BY id ASC LIMIT ?, ?",
+		postID, count*page, count,
+	)
+	if err != nil {
+		return smolboard.NoComments, errors.Wrap(err, "Failed to query comments")
+	}
+
+	defer q.Close()
+
+	for q.Next() {
+		var c smolboard.Comment
+
+		if err := q.StructScan(&c); err != nil {
+			return smolboard.NoComments, errors.Wrap(err, "Failed to scan comment")
+		}
+
+		list.Comments = append(list.Comments, c)
+	}
+
+	return list, nil
+}
+
+// AddComment comments on the post as the current user, who must be at least a
+// user.
+func (d *Transaction) AddComment(postID int64, body string) (*smolboard.Comment, error) {
+	if err := smolboard.CommentIsValid(body); err != nil {
+		return nil, err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionUser, true); err != nil {
+		return nil, err
+	}
+
+	if _, err := d.PostQuickGet(postID); err != nil {
+		return nil, err
+	}
+
+	var c = smolboard.Comment{
+		ID:        int64(commentIDGen.Generate()),
+		PostID:    postID,
+		Commenter: &d.Session.Username,
+		Body:      body,
+	}
+
+	_, err := d.Exec(
+		"INSERT INTO comments VALUES (?, ?, ?, ?)",
+		c.ID, c.PostID, c.Commenter, c.Body,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to insert comment")
+	}
+
+	return &c, nil
+}
+
+// DeleteComment deletes the comment. Only the commenter or an administrator
+// can do this.
+func (d *Transaction) DeleteComment(id int64) error {
+	var commenter *string
+
+	err := d.QueryRow("SELECT commenter FROM comments WHERE id = ?", id).Scan(&commenter)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return smolboard.ErrCommentNotFound
+		}
+		return errors.Wrap(err, "Failed to scan comment's commenter")
+	}
+
+	// Comments of deleted users have no commenter, so only administrators can
+	// delete them.
+	if commenter == nil {
+		err = d.HasPermission(smolboard.PermissionAdministrator, true)
+	} else {
+		err = d.IsUserOrHasPermOver(smolboard.PermissionAdministrator, *commenter)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.Exec("DELETE FROM comments WHERE id = ?", id); err != nil {
+		return errors.Wrap(err, "Failed to delete comment")
+	}
+
+	return nil
+}
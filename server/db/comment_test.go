@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/diamondburned/smolboard/smolboard"
+)
+
+func TestComments(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+	other := newTestUser(t, d, owner.AuthToken, "かぐや", smolboard.PermissionUser)
+
+	var post int64
+	var userComment, otherComment *smolboard.Comment
+
+	t.Run("Setup", func(t *testing.T) {
+		post = testNewTaggedPost(t, testBeginTx(t, d, owner.AuthToken), "cat")
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		if _, err := tx.AddComment(post, "  "); err != smolboard.ErrEmptyComment {
+			t.Fatal("Unexpected error adding an empty comment:", err)
+		}
+
+		long := strings.Repeat("a", smolboard.MaxCommentLen+1)
+		if _, err := tx.AddComment(post, long); err != smolboard.ErrCommentTooLong {
+			t.Fatal("Unexpected error adding a long comment:", err)
+		}
+
+		if _, err := tx.AddComment(1, "hi"); err != smolboard.ErrPostNotFound {
+			t.Fatal("Unexpected error commenting on an unknown post:", err)
+		}
+
+		c, err := tx.AddComment(post, "cute cat")
+		if err != nil {
+			t.Fatal("Failed to add comment:", err)
+		}
+
+		if c.Commenter == nil || *c.Commenter != user.Username {
+			t.Fatalf("Unexpected comment: %#v", c)
+		}
+
+		userComment = c
+	})
+
+	t.Run("AddOther", func(t *testing.T) {
+		tx := testBeginTx(t, d, other.AuthToken)
+
+		c, err := tx.AddComment(post, "agreed")
+		if err != nil {
+			t.Fatal("Failed to add comment:", err)
+		}
+
+		otherComment = c
+
+		if err := tx.DeleteComment(userComment.ID); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error deleting another user's comment:", err)
+		}
+	})
+
+	t.Run("Guest", func(t *testing.T) {
+		err := d.AcquireGuest(context.TODO(), func(tx *Transaction) error {
+			_, err := tx.AddComment(post, "hello")
+			return err
+		})
+		if err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error commenting as guest:", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		l, err := tx.Comments(post, 1, 1)
+		if err != nil {
+			t.Fatal("Failed to get comments:", err)
+		}
+
+		if l.Total != 2 || len(l.Comments) != 1 || l.Comments[0].ID != otherComment.ID {
+			t.Fatalf("Unexpected second page: %#v", l)
+		}
+
+		if _, err := tx.Comments(post, 101, 0); err != smolboard.ErrPageCountLimit {
+			t.Fatal("Unexpected error listing too many comments:", err)
+		}
+
+		if err := tx.DeleteComment(userComment.ID); err != nil {
+			t.Fatal("Failed to delete own comment:", err)
+		}
+
+		if err := tx.DeleteComment(userComment.ID); err != smolboard.ErrCommentNotFound {
+			t.Fatal("Unexpected error deleting a deleted comment:", err)
+		}
+	})
+
+	t.Run("DeleteUser", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		if err := tx.DeleteUser(other.Username); err != nil {
+			t.Fatal("Failed to delete user:", err)
+		}
+	})
+
+	t.Run("DeletedCommenter", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		// Nobody but administrators can delete comments of deleted users.
+		if err := tx.DeleteComment(otherComment.ID); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error deleting a deleted user's comment:", err)
+		}
+	})
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	l, err := tx.Comments(post, 25, 0)
+	if err != nil {
+		t.Fatal("Failed to get comments:", err)
+	}
+
+	if l.Total != 1 || l.Comments[0].Commenter != nil {
+		t.Fatalf("Unexpected comments after deleting the commenter: %#v", l)
+	}
+
+	if err := tx.DeleteComment(otherComment.ID); err != nil {
+		t.Fatal("Failed to delete comment as owner:", err)
+	}
+}
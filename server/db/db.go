@@ -68,8 +68,222 @@ var migrations = []string{`
 		-- Prevent multiple of the same tags from appearing in one post.
 		UNIQUE (postid, tagname COLLATE NOCASE)
 	);
+`, `
+
+	CREATE INDEX posttags_tagname ON posttags(tagname);
+
+	-- Tags are denormalized from posttags to avoid counting them on every
+	-- lookup. The triggers below keep the counts in sync.
+	CREATE TABLE tags (
+		name    TEXT    PRIMARY KEY,
+		count   INTEGER NOT NULL DEFAULT 0, -- number of posts with the tag
+		created INTEGER NOT NULL            -- unixnano
+	);
+
+	CREATE TRIGGER posttags_insert AFTER INSERT ON posttags BEGIN
+		INSERT INTO tags (name, count, created) VALUES (NEW.tagname, 1, ` + sqlUnixNano + `)
+			ON CONFLICT (name) DO UPDATE SET count = count + 1;
+	END;
+
+	CREATE TRIGGER posttags_delete AFTER DELETE ON posttags BEGIN
+		UPDATE tags SET count = count - 1 WHERE name = OLD.tagname;
+	END;
+
+	CREATE TRIGGER posttags_update AFTER UPDATE OF tagname ON posttags BEGIN
+		UPDATE tags SET count = count - 1 WHERE name = OLD.tagname;
+		INSERT INTO tags (name, count, created) VALUES (NEW.tagname, 1, ` + sqlUnixNano + `)
+			ON CONFLICT (name) DO UPDATE SET count = count + 1;
+	END;
+
+	INSERT INTO tags (name, count, created)
+		SELECT tagname, COUNT(1), ` + sqlUnixNano + ` FROM posttags GROUP BY tagname;
+`, `
+
+	CREATE TABLE tagaliases (
+		alias   TEXT PRIMARY KEY,
+		tagname TEXT NOT NULL -- canonical tag, which is never an alias
+	);
+
+	CREATE INDEX tagaliases_tagname ON tagaliases(tagname);
+`, `
+
+	CREATE TABLE tagimplications (
+		tagname TEXT NOT NULL,
+		implied TEXT NOT NULL,
+		PRIMARY KEY (tagname, implied)
+	);
+
+	CREATE INDEX tagimplications_implied ON tagimplications(implied);
+`, `
+
+	CREATE TABLE tagcategories (
+		name      TEXT    PRIMARY KEY,
+		color     TEXT    NOT NULL,
+		sortorder INTEGER NOT NULL DEFAULT 0
+	);
+`, `
+
+	CREATE TABLE tagrevisions (
+		id          INTEGER PRIMARY KEY, -- Snowflake
+		tagname     TEXT    NOT NULL,
+		description TEXT    NOT NULL,    -- Markdown
+		editor      TEXT REFERENCES users(username)
+			ON UPDATE CASCADE
+			ON DELETE SET NULL
+	);
+
+	CREATE INDEX tagrevisions_tagname ON tagrevisions(tagname, id);
+`, `
+
+	CREATE TABLE blacklists (
+		username TEXT NOT NULL REFERENCES users(username)
+			ON UPDATE CASCADE
+			ON DELETE CASCADE,
+		-- Blacklisted tags match posts' tags in any case.
+		tagname  TEXT NOT NULL COLLATE NOCASE,
+		PRIMARY KEY (username, tagname)
+	);
+`, `
+
+	CREATE TABLE tagedits (
+		id      INTEGER PRIMARY KEY, -- Snowflake
+		postid  INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+		tagname TEXT    NOT NULL,
+		action  TEXT    NOT NULL,
+		actor   TEXT    REFERENCES users(username)
+			ON UPDATE CASCADE
+			ON DELETE SET NULL
+	);
+
+	CREATE INDEX tagedits_postid ON tagedits(postid, id);
+`, `
+
+	CREATE TABLE restrictedtags (
+		tagname    TEXT    PRIMARY KEY COLLATE NOCASE,
+		permission INTEGER NOT NULL
+	);
+`, `
+
+	ALTER TABLE posts ADD COLUMN title       TEXT NOT NULL DEFAULT '';
+	ALTER TABLE posts ADD COLUMN description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE posts ADD COLUMN source      TEXT NOT NULL DEFAULT '';
+
+	-- Full-text index over the posts' text fields, kept in sync by triggers.
+	CREATE VIRTUAL TABLE postsearch USING fts5(
+		title, description, source,
+		content='posts', content_rowid='id'
+	);
+
+	CREATE TRIGGER postsearch_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO postsearch(rowid, title, description, source)
+		VALUES (new.id, new.title, new.description, new.source);
+	END;
+
+	CREATE TRIGGER postsearch_delete AFTER DELETE ON posts BEGIN
+		INSERT INTO postsearch(postsearch, rowid, title, description, source)
+		VALUES ('delete', old.id, old.title, old.description, old.source);
+	END;
+
+	CREATE TRIGGER postsearch_update AFTER UPDATE OF title, description, source ON posts BEGIN
+		INSERT INTO postsearch(postsearch, rowid, title, description, source)
+		VALUES ('delete', old.id, old.title, old.description, old.source);
+		INSERT INTO postsearch(rowid, title, description, source)
+		VALUES (new.id, new.title, new.description, new.source);
+	END;
+
+	INSERT INTO postsearch(postsearch) VALUES ('rebuild');
+`, `
+
+	CREATE TABLE comments (
+		id        INTEGER PRIMARY KEY, -- Snowflake
+		postid    INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+		commenter TEXT REFERENCES users(username)
+			ON UPDATE CASCADE
+			ON DELETE SET NULL,
+		body      TEXT    NOT NULL
+	);
+
+	CREATE INDEX comments_postid ON comments(postid, id);
+`, `
+
+	CREATE TABLE favorites (
+		username TEXT NOT NULL REFERENCES users(username)
+			ON UPDATE CASCADE
+			ON DELETE CASCADE,
+		postid   INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+		PRIMARY KEY (username, postid)
+	);
+
+	CREATE INDEX favorites_postid ON favorites(postid);
+`, `
+
+	-- The score is cached, so posts can be sorted by it.
+	ALTER TABLE posts ADD COLUMN score INTEGER NOT NULL DEFAULT 0;
+
+	CREATE INDEX posts_score ON posts(score, id);
+
+	CREATE TABLE votes (
+		username TEXT NOT NULL REFERENCES users(username)
+			ON UPDATE CASCADE
+			ON DELETE CASCADE,
+		postid   INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+		value    INTEGER NOT NULL CHECK (value IN (-1, 1)),
+		PRIMARY KEY (username, postid)
+	);
+
+	CREATE TRIGGER votes_insert AFTER INSERT ON votes BEGIN
+		UPDATE posts SET score = score + new.value WHERE id = new.postid;
+	END;
+
+	CREATE TRIGGER votes_update AFTER UPDATE OF value ON votes BEGIN
+		UPDATE posts SET score = score - old.value + new.value WHERE id = new.postid;
+	END;
+
+	CREATE TRIGGER votes_delete AFTER DELETE ON votes BEGIN
+		UPDATE posts SET score = score - old.value WHERE id = old.postid;
+	END;
+`, `
+
+	CREATE TABLE pools (
+		id          INTEGER PRIMARY KEY, -- Snowflake
+		name        TEXT    NOT NULL,
+		description TEXT    NOT NULL,
+		permission  INTEGER NOT NULL,
+		owner       TEXT REFERENCES users(username)
+			ON UPDATE CASCADE
+			ON DELETE SET NULL
+	);
+
+	CREATE TABLE poolposts (
+		poolid   INTEGER NOT NULL REFERENCES pools(id) ON DELETE CASCADE,
+		postid   INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL, -- may have gaps
+		PRIMARY KEY (poolid, postid)
+	);
+
+	CREATE INDEX poolposts_postid ON poolposts(postid);
+`, `
+
+	-- Children are kept when their parent is deleted.
+	ALTER TABLE posts ADD COLUMN parent INTEGER REFERENCES posts(id) ON DELETE SET NULL;
+
+	CREATE INDEX posts_parent ON posts(parent);
+`, `
+
+	-- Hexadecimal SHA-256 of the content, or empty for older posts.
+	ALTER TABLE posts ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+
+	CREATE INDEX posts_hash ON posts(hash);
+`, `
+
+	-- 64-bit perceptual hash, or NULL if it couldn't be computed.
+	ALTER TABLE posts ADD COLUMN phash INTEGER;
 `}
 
+// sqlUnixNano is the SQL expression for the current time in unixnano. SQLite
+// only has the time in milliseconds.
+const sqlUnixNano = `(CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER) * 1000000)`
+
 type DBConfig struct {
 	Owner         string `toml:"owner"`
 	DatabasePath  string `toml:"databasePath"`
@@ -233,7 +447,13 @@ func errIsConstraint(err error) bool {
 	if !errors.As(err, &sqliteErr) {
 		return false
 	}
-	return sqliteErr.Code() == sqlitelib.SQLITE_CONSTRAINT_UNIQUE
+	// TEXT primary keys are reported separately from UNIQUE constraints.
+	switch sqliteErr.Code() {
+	case sqlitelib.SQLITE_CONSTRAINT_UNIQUE, sqlitelib.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return true
+	default:
+		return false
+	}
 }
 
 // execChanged returns false if no rows were affected.
@@ -26,6 +26,10 @@ type Transaction struct {
 	// As we acquire an entire transaction, it is safe to store our own local
 	// session state as long as we keep it up to date on our own calls.
 	Session smolboard.Session
+
+	// ShowBlacklisted overrides the user's blacklist, which hides posts with
+	// blacklisted tags by default.
+	ShowBlacklisted bool
 }
 
 // BeginTx starts a new transaction belonging to the given session. If session
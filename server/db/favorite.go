@@ -0,0 +1,62 @@
+package db
+
+import (
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+// FavoritePost adds the post to the current user's favorites. Favoriting a post
+// twice does nothing.
+func (d *Transaction) FavoritePost(postID int64) error {
+	if err := d.HasPermission(smolboard.PermissionUser, true); err != nil {
+		return err
+	}
+
+	if _, err := d.PostQuickGet(postID); err != nil {
+		return err
+	}
+
+	_, err := d.Exec(
+		"INSERT OR IGNORE INTO favorites VALUES (?, ?)",
+		d.Session.Username, postID,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to insert favorite")
+	}
+
+	return nil
+}
+
+// UnfavoritePost removes the post from the current user's favorites.
+// Unfavoriting a post that isn't favorited does nothing.
+func (d *Transaction) UnfavoritePost(postID int64) error {
+	if _, err := d.PostQuickGet(postID); err != nil {
+		return err
+	}
+
+	_, err := d.Exec(
+		"DELETE FROM favorites WHERE username = ? AND postid = ?",
+		d.Session.Username, postID,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete favorite")
+	}
+
+	return nil
+}
+
+// favorites returns the number of users who favorited the post and whether the
+// current user is one of them.
+func (d *Transaction) favorites(postID int64) (count int, favorited bool, err error) {
+	r := d.QueryRow(
+		"SELECT COUNT(1), COALESCE(SUM(username = ?), 0) FROM favorites WHERE postid = ?",
+		d.Session.Username, postID,
+	)
+
+	var own int
+	if err := r.Scan(&count, &own); err != nil {
+		return 0, false, errors.Wrap(err, "Failed to scan favorites")
+	}
+
+	return count, own > 0, nil
+}
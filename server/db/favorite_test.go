@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-test/deep"
+)
+
+func TestFavorites(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	var cat, dog int64
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		cat = testNewTaggedPost(t, tx, "cat")
+		dog = testNewTaggedPost(t, tx, "dog")
+
+		if err := tx.FavoritePost(cat); err != nil {
+			t.Fatal("Failed to favorite post:", err)
+		}
+	})
+
+	t.Run("Favorite", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		for _, id := range []int64{cat, dog, dog} {
+			if err := tx.FavoritePost(id); err != nil {
+				t.Fatal("Failed to favorite post:", err)
+			}
+		}
+
+		if err := tx.FavoritePost(1); err != smolboard.ErrPostNotFound {
+			t.Fatal("Unexpected error favoriting an unknown post:", err)
+		}
+
+		p, err := tx.Post(cat)
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		if p.Favorites != 2 || !p.Favorited {
+			t.Fatalf("Unexpected favorites: %d, %v", p.Favorites, p.Favorited)
+		}
+
+		if err := tx.UnfavoritePost(cat); err != nil {
+			t.Fatal("Failed to unfavorite post:", err)
+		}
+
+		p, err = tx.Post(cat)
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		if p.Favorites != 1 || p.Favorited {
+			t.Fatalf("Unexpected favorites after unfavoriting: %d, %v", p.Favorites, p.Favorited)
+		}
+	})
+
+	t.Run("Guest", func(t *testing.T) {
+		err := d.AcquireGuest(context.TODO(), func(tx *Transaction) error {
+			return tx.FavoritePost(cat)
+		})
+		if err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error favoriting as guest:", err)
+		}
+	})
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	var tests = []struct {
+		query string
+		posts []int64
+	}{
+		{"fav:" + user.Username, []int64{dog}},
+		{"fav:" + owner.Username, []int64{cat}},
+		{"fav:" + owner.Username + " OR fav:" + user.Username, []int64{dog, cat}},
+		{"-fav:" + owner.Username, []int64{dog}},
+		{"fav:nobody", []int64{}},
+	}
+
+	for _, test := range tests {
+		s, err := tx.PostSearch(test.query, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatalf("Failed to search %q: %v", test.query, err)
+		}
+
+		var ids = make([]int64, len(s.Posts))
+		for i, p := range s.Posts {
+			ids[i] = p.ID
+		}
+
+		if eq := deep.Equal(ids, test.posts); eq != nil {
+			t.Fatalf("Unexpected posts searching %q: %v", test.query, eq)
+		}
+	}
+}
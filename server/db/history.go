@@ -0,0 +1,140 @@
+package db
+
+import (
+	"time"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+// logTagEdit logs the change to the post's tags as done by the current user.
+func (d *Transaction) logTagEdit(postID int64, tag string, action smolboard.TagEditAction) error {
+	var actor *string
+	if d.Session.Username != "" {
+		actor = &d.Session.Username
+	}
+
+	_, err := d.Exec(
+		"INSERT INTO tagedits VALUES (?, ?, ?, ?, ?)",
+		int64(tagEditIDGen.Generate()), postID, tag, action, actor,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to log tag edit")
+	}
+
+	return nil
+}
+
+// PostHistory returns the logged changes to the post's tags, newest first.
+func (d *Transaction) PostHistory(postID int64) ([]smolboard.TagEdit, error) {
+	// Make sure the user can see the post.
+	if _, err := d.PostQuickGet(postID); err != nil {
+		return nil, err
+	}
+
+	r, err := d.Queryx(
+		"SELECT * FROM tagedits WHERE postid = ? ORDER BY id DESC",
+		postID,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query tag edits")
+	}
+
+	defer r.Close()
+
+	var edits = []smolboard.TagEdit{}
+
+	for r.Next() {
+		var edit smolboard.TagEdit
+
+		if err := r.StructScan(&edit); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan tag edit")
+		}
+
+		edits = append(edits, edit)
+	}
+
+	return edits, nil
+}
+
+// RevertPostTags reverts the post's tags to what they were at the given time
+// by undoing every logged change made after it. Implied tags are not added,
+// and the undoing changes are logged like any other. Only administrators can
+// do this, and only if they can apply every tag that would be changed.
+func (d *Transaction) RevertPostTags(postID int64, t time.Time) error {
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	if err := d.canChangePost(postID); err != nil {
+		return err
+	}
+
+	// Changes made within the same millisecond as the given time are kept.
+	// Times before any Snowflake revert everything.
+	var after int64
+	if t.After(snowflakeEpoch) {
+		after = NewZeroID(t) + 1<<22
+	}
+
+	r, err := d.Query(
+		"SELECT tagname, action FROM tagedits WHERE postid = ? AND id >= ? ORDER BY id ASC",
+		postID, after,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to query tag edits")
+	}
+
+	defer r.Close()
+
+	// The earliest change to each tag tells whether the post had it at the
+	// time.
+	var undo []smolboard.TagEdit
+	var seen = map[string]struct{}{}
+
+	for r.Next() {
+		var edit smolboard.TagEdit
+
+		if err := r.Scan(&edit.TagName, &edit.Action); err != nil {
+			return errors.Wrap(err, "Failed to scan tag edit")
+		}
+
+		if _, ok := seen[edit.TagName]; ok {
+			continue
+		}
+
+		seen[edit.TagName] = struct{}{}
+		undo = append(undo, edit)
+	}
+
+	// Close early so the tags can be changed.
+	r.Close()
+
+	// Check every tag first so the post isn't left half reverted.
+	for _, edit := range undo {
+		if err := d.canApplyTag(edit.TagName); err != nil {
+			return err
+		}
+	}
+
+	for _, edit := range undo {
+		switch edit.Action {
+		case smolboard.TagEditAdd:
+			err = d.deletePostTag(postID, edit.TagName)
+			if errors.Is(err, smolboard.ErrPostNotFound) {
+				err = nil // already removed
+			}
+		case smolboard.TagEditRemove:
+			err = d.insertPostTag(postID, edit.TagName)
+			if errors.Is(err, smolboard.ErrTagAlreadyAdded) {
+				err = nil
+			}
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "Failed to revert tag %q", edit.TagName)
+		}
+	}
+
+	return nil
+}
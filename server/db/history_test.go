@@ -0,0 +1,159 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-test/deep"
+)
+
+func TestPostHistory(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	var post int64
+	var before time.Time
+
+	t.Run("Edit", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		post = testNewTaggedPost(t, tx, "cat", "cute")
+
+		// Make sure the changes below aren't in the same millisecond.
+		time.Sleep(2 * time.Millisecond)
+		before = time.Now()
+		time.Sleep(2 * time.Millisecond)
+
+		if err := tx.UntagPost(post, "cat"); err != nil {
+			t.Fatal("Failed to untag post:", err)
+		}
+		if err := tx.TagPost(post, "dog"); err != nil {
+			t.Fatal("Failed to tag post:", err)
+		}
+		if err := tx.UntagPost(post, "cute"); err != nil {
+			t.Fatal("Failed to untag post:", err)
+		}
+		if err := tx.TagPost(post, "cute"); err != nil {
+			t.Fatal("Failed to tag post:", err)
+		}
+
+		if err := tx.RevertPostTags(post, before); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error reverting as user:", err)
+		}
+
+		edits, err := tx.PostHistory(post)
+		if err != nil {
+			t.Fatal("Failed to get history:", err)
+		}
+
+		type edit struct {
+			Tag    string
+			Action smolboard.TagEditAction
+		}
+
+		var got = make([]edit, len(edits))
+		for i, e := range edits {
+			if e.Actor == nil || *e.Actor != user.Username {
+				t.Fatalf("Unexpected actor of %#v", e)
+			}
+			got[i] = edit{e.TagName, e.Action}
+		}
+
+		var expect = []edit{
+			{"cute", smolboard.TagEditAdd},
+			{"cute", smolboard.TagEditRemove},
+			{"dog", smolboard.TagEditAdd},
+			{"cat", smolboard.TagEditRemove},
+			{"cute", smolboard.TagEditAdd},
+			{"cat", smolboard.TagEditAdd},
+		}
+
+		if eq := deep.Equal(got, expect); eq != nil {
+			t.Fatal("Unexpected history:", eq)
+		}
+	})
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	if err := tx.RevertPostTags(post, before); err != nil {
+		t.Fatal("Failed to revert post:", err)
+	}
+
+	if eq := deep.Equal(testPostTags(t, tx, post), []string{"cat", "cute"}); eq != nil {
+		t.Fatal("Unexpected tags after revert:", eq)
+	}
+
+	edits, err := tx.PostHistory(post)
+	if err != nil {
+		t.Fatal("Failed to get history:", err)
+	}
+
+	// The revert itself is logged, so it can be reverted too.
+	if len(edits) != 8 || edits[0].Actor == nil || *edits[0].Actor != owner.Username {
+		t.Fatalf("Unexpected history after revert: %#v", edits)
+	}
+
+	if err := tx.RevertPostTags(post, time.Time{}); err != nil {
+		t.Fatal("Failed to revert post to the beginning:", err)
+	}
+
+	if tags := testPostTags(t, tx, post); len(tags) != 0 {
+		t.Fatal("Unexpected tags after reverting everything:", tags)
+	}
+}
+
+func TestPostHistoryTagChanges(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	post := testNewTaggedPost(t, tx, "kitty")
+
+	// Aliasing, renaming and backfilling implications change the tags of
+	// existing posts, so they should be logged as well.
+	if err := tx.AddTagAlias("cat", "kitty"); err != nil {
+		t.Fatal("Failed to add alias:", err)
+	}
+	if err := tx.RenameTag("cat", "feline"); err != nil {
+		t.Fatal("Failed to rename tag:", err)
+	}
+	if err := tx.AddTagImplication("feline", "animal"); err != nil {
+		t.Fatal("Failed to add implication:", err)
+	}
+	if _, err := tx.BackfillTagImplications("feline"); err != nil {
+		t.Fatal("Failed to backfill implications:", err)
+	}
+
+	edits, err := tx.PostHistory(post)
+	if err != nil {
+		t.Fatal("Failed to get history:", err)
+	}
+
+	type edit struct {
+		Tag    string
+		Action smolboard.TagEditAction
+	}
+
+	var got = make([]edit, len(edits))
+	for i, e := range edits {
+		got[i] = edit{e.TagName, e.Action}
+	}
+
+	var expect = []edit{
+		{"animal", smolboard.TagEditAdd},
+		{"feline", smolboard.TagEditAdd},
+		{"cat", smolboard.TagEditRemove},
+		{"cat", smolboard.TagEditAdd},
+		{"kitty", smolboard.TagEditRemove},
+		{"kitty", smolboard.TagEditAdd},
+	}
+
+	if eq := deep.Equal(got, expect); eq != nil {
+		t.Fatal("Unexpected history:", eq)
+	}
+}
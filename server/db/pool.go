@@ -0,0 +1,391 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+// sqlPoolVisible is the SQL condition for pools visible to the user. The
+// arguments are the username and the user's permission.
+const sqlPoolVisible = "(pools.owner = ? OR pools.permission <= ?)"
+
+// Pools returns a page of the pools visible to the user, newest first.
+func (d *Transaction) Pools(count, page uint) (smolboard.PoolList, error) {
+	if count > 100 {
+		return smolboard.NoPools, smolboard.ErrPageCountLimit
+	}
+
+	p, err := d.Permission()
+	if err != nil {
+		return smolboard.NoPools, err
+	}
+
+	var list = smolboard.PoolList{
+		Pools: make([]smolboard.Pool, 0, count),
+	}
+
+	r := d.QueryRow("SELECT COUNT(1) FROM pools WHERE "+sqlPoolVisible, d.Session.Username, p)
+
+	if err := r.Scan(&list.Total); err != nil {
+		return smolboard.NoPools, errors.Wrap(err, "Failed to scan total")
+	}
+
+	q, err := d.Queryx(
+		"SELECT * FROM pools WHERE "+sqlPoolVisible+" ORDER BY id DESC LIMIT ?, ?",
+		d.Session.Username, p, count*page, count,
+	)
+	if err != nil {
+		return smolboard.NoPools, errors.Wrap(err, "Failed to query pools")
+	}
+
+	defer q.Close()
+
+	for q.Next() {
+		var pool smolboard.Pool
+
+		if err := q.StructScan(&pool); err != nil {
+			return smolboard.NoPools, errors.Wrap(err, "Failed to scan pool")
+		}
+
+		list.Pools = append(list.Pools, pool)
+	}
+
+	return list, nil
+}
+
+// Pool returns the pool with its posts in order. Posts that the user cannot see
+// or that are hidden by the user's blacklist are left out.
+func (d *Transaction) Pool(id int64) (*smolboard.PoolExtended, error) {
+	pool, err := d.poolQuickGet(id)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := d.Permission()
+	if err != nil {
+		return nil, err
+	}
+
+	var query = `
+		SELECT posts.* FROM poolposts
+		JOIN   posts ON posts.id = poolposts.postid
+		WHERE  poolposts.poolid = ? AND (posts.poster = ? OR posts.permission <= ?)`
+	var args = []interface{}{id, d.Session.Username, p}
+
+	if d.blacklisted() {
+		query += " AND (posts.poster IS ? OR NOT " + sqlBlacklisted("posts.id") + ")"
+		args = append(args, d.Session.Username, d.Session.Username)
+	}
+
+	q, err := d.Queryx(query+" ORDER BY poolposts.position ASC", args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query pool posts")
+	}
+
+	defer q.Close()
+
+	var poolEx = smolboard.PoolExtended{
+		Pool:  *pool,
+		Posts: []smolboard.Post{},
+	}
+
+	for q.Next() {
+		var post smolboard.Post
+
+		if err := q.StructScan(&post); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan post")
+		}
+
+		poolEx.Posts = append(poolEx.Posts, post)
+	}
+
+	return &poolEx, nil
+}
+
+// CreatePool creates an empty pool owned by the current user, who must be at
+// least a user. The pool's permission can be as high as the user's.
+func (d *Transaction) CreatePool(
+	name, description string, perm smolboard.Permission) (*smolboard.Pool, error) {
+
+	err := smolboard.PoolEditIsValid(smolboard.PoolEdit{
+		Name:        &name,
+		Description: &description,
+		Permission:  &perm,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionUser, true); err != nil {
+		return nil, err
+	}
+
+	if err := d.HasPermission(perm, true); err != nil {
+		return nil, err
+	}
+
+	var pool = smolboard.Pool{
+		ID:          int64(poolIDGen.Generate()),
+		Name:        name,
+		Description: description,
+		Permission:  perm,
+		Owner:       &d.Session.Username,
+	}
+
+	_, err = d.Exec(
+		"INSERT INTO pools VALUES (?, ?, ?, ?, ?)",
+		pool.ID, pool.Name, pool.Description, pool.Permission, pool.Owner,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to insert pool")
+	}
+
+	return &pool, nil
+}
+
+// EditPool changes the pool. Only the owner or an administrator can do this.
+// Like posts, the permission can be as high as the user's if the pool is
+// theirs.
+func (d *Transaction) EditPool(id int64, e smolboard.PoolEdit) error {
+	if err := smolboard.PoolEditIsValid(e); err != nil {
+		return err
+	}
+
+	pool, err := d.canChangePool(id)
+	if err != nil {
+		return err
+	}
+
+	if e.Permission != nil {
+		if err := d.HasPermOverUser(*e.Permission, poolOwner(pool)); err != nil {
+			return err
+		}
+	}
+
+	_, err = d.Exec(
+		`UPDATE pools SET
+			name        = COALESCE(?, name),
+			description = COALESCE(?, description),
+			permission  = COALESCE(?, permission)
+		WHERE id = ?`,
+		e.Name, e.Description, e.Permission, id,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to update pool")
+	}
+
+	return nil
+}
+
+// DeletePool deletes the pool but not its posts. Only the owner or an
+// administrator can do this.
+func (d *Transaction) DeletePool(id int64) error {
+	if _, err := d.canChangePool(id); err != nil {
+		return err
+	}
+
+	if _, err := d.Exec("DELETE FROM pools WHERE id = ?", id); err != nil {
+		return errors.Wrap(err, "Failed to delete pool")
+	}
+
+	return nil
+}
+
+// AddPoolPost adds the post to the end of the pool. The user must be able to
+// change the pool and see the post.
+func (d *Transaction) AddPoolPost(poolID, postID int64) error {
+	if _, err := d.canChangePool(poolID); err != nil {
+		return err
+	}
+
+	if _, err := d.PostQuickGet(postID); err != nil {
+		return err
+	}
+
+	var count int
+
+	err := d.QueryRow("SELECT COUNT(1) FROM poolposts WHERE poolid = ?", poolID).Scan(&count)
+	if err != nil {
+		return errors.Wrap(err, "Failed to count pool posts")
+	}
+
+	if count >= smolboard.MaxPoolPosts {
+		return smolboard.ErrPoolFull
+	}
+
+	_, err = d.Exec(`
+		INSERT INTO poolposts VALUES (?, ?, (
+			SELECT COALESCE(MAX(position) + 1, 0) FROM poolposts WHERE poolid = ?))`,
+		poolID, postID, poolID,
+	)
+	if err != nil {
+		if errIsConstraint(err) {
+			return smolboard.ErrPostAlreadyInPool
+		}
+		return errors.Wrap(err, "Failed to insert pool post")
+	}
+
+	return nil
+}
+
+// RemovePoolPost removes the post from the pool.
+func (d *Transaction) RemovePoolPost(poolID, postID int64) error {
+	if _, err := d.canChangePool(poolID); err != nil {
+		return err
+	}
+
+	r, err := d.Exec("DELETE FROM poolposts WHERE poolid = ? AND postid = ?", poolID, postID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete pool post")
+	}
+
+	if count, err := r.RowsAffected(); err == nil && count == 0 {
+		return smolboard.ErrPostNotInPool
+	}
+
+	return nil
+}
+
+// ReorderPool sorts the pool's posts in the order of the given post IDs, which
+// must have all of the pool's posts exactly once.
+func (d *Transaction) ReorderPool(poolID int64, postIDs []int64) error {
+	if _, err := d.canChangePool(poolID); err != nil {
+		return err
+	}
+
+	q, err := d.Query("SELECT postid FROM poolposts WHERE poolid = ?", poolID)
+	if err != nil {
+		return errors.Wrap(err, "Failed to query pool posts")
+	}
+
+	defer q.Close()
+
+	// Check the order before changing anything, so an invalid order fails with
+	// ErrInvalidPoolOrder without doing any writes.
+	var unsorted = map[int64]bool{}
+
+	for q.Next() {
+		var postID int64
+
+		if err := q.Scan(&postID); err != nil {
+			return errors.Wrap(err, "Failed to scan pool post")
+		}
+
+		unsorted[postID] = true
+	}
+
+	if len(unsorted) != len(postIDs) {
+		return smolboard.ErrPoolOrderMismatch
+	}
+
+	for _, postID := range postIDs {
+		if !unsorted[postID] {
+			return smolboard.ErrPoolOrderMismatch
+		}
+		delete(unsorted, postID)
+	}
+
+	for i, postID := range postIDs {
+		_, err := d.Exec(
+			"UPDATE poolposts SET position = ? WHERE poolid = ? AND postid = ?",
+			i, poolID, postID,
+		)
+		if err != nil {
+			return errors.Wrap(err, "Failed to update pool post")
+		}
+	}
+
+	return nil
+}
+
+// postPools returns the pools that the post is in and the user can see.
+func (d *Transaction) postPools(postID int64) ([]smolboard.Pool, error) {
+	p, err := d.Permission()
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := d.Queryx(`
+		SELECT pools.* FROM poolposts
+		JOIN   pools ON pools.id = poolposts.poolid
+		WHERE  poolposts.postid = ? AND `+sqlPoolVisible+`
+		ORDER  BY pools.id ASC`,
+		postID, d.Session.Username, p,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query post's pools")
+	}
+
+	defer q.Close()
+
+	var pools = []smolboard.Pool{}
+
+	for q.Next() {
+		var pool smolboard.Pool
+
+		if err := q.StructScan(&pool); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan pool")
+		}
+
+		pools = append(pools, pool)
+	}
+
+	return pools, nil
+}
+
+// poolQuickGet returns the pool without its posts if the user can see it.
+func (d *Transaction) poolQuickGet(id int64) (*smolboard.Pool, error) {
+	p, err := d.Permission()
+	if err != nil {
+		return nil, err
+	}
+
+	var pool smolboard.Pool
+
+	err = d.QueryRowx(
+		"SELECT * FROM pools WHERE id = ? AND "+sqlPoolVisible,
+		id, d.Session.Username, p,
+	).StructScan(&pool)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, smolboard.ErrPoolNotFound
+		}
+		return nil, errors.Wrap(err, "Failed to get pool")
+	}
+
+	return &pool, nil
+}
+
+// canChangePool returns the pool if the user can change it, which is only the
+// owner or an administrator.
+func (d *Transaction) canChangePool(id int64) (*smolboard.Pool, error) {
+	pool, err := d.poolQuickGet(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pools of deleted users have no owner, so only administrators can change
+	// them.
+	if pool.Owner == nil {
+		err = d.HasPermission(smolboard.PermissionAdministrator, true)
+	} else {
+		err = d.IsUserOrHasPermOver(smolboard.PermissionAdministrator, *pool.Owner)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// poolOwner returns the pool's owner, or an empty string if the owner is
+// deleted.
+func poolOwner(pool *smolboard.Pool) string {
+	if pool.Owner == nil {
+		return ""
+	}
+	return *pool.Owner
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-test/deep"
+)
+
+func TestPools(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+	other := newTestUser(t, d, owner.AuthToken, "かぐや", smolboard.PermissionUser)
+
+	var pages [3]int64
+	var pool *smolboard.Pool
+	var private *smolboard.Pool
+
+	poolPosts := func(t *testing.T, tx *Transaction, id int64) []int64 {
+		t.Helper()
+
+		p, err := tx.Pool(id)
+		if err != nil {
+			t.Fatal("Failed to get pool:", err)
+		}
+
+		var ids = make([]int64, len(p.Posts))
+		for i, post := range p.Posts {
+			ids[i] = post.ID
+		}
+
+		return ids
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		for i := range pages {
+			pages[i] = testNewTaggedPost(t, tx, "comic")
+		}
+
+		var err error
+
+		if _, err = tx.CreatePool(" ", "", smolboard.PermissionGuest); err != smolboard.ErrIllegalPoolName {
+			t.Fatal("Unexpected error creating a pool with no name:", err)
+		}
+
+		_, err = tx.CreatePool("Comic", "", smolboard.PermissionAdministrator)
+		if err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error creating a pool above own permission:", err)
+		}
+
+		pool, err = tx.CreatePool("Comic", "A short comic.", smolboard.PermissionGuest)
+		if err != nil {
+			t.Fatal("Failed to create pool:", err)
+		}
+
+		private, err = tx.CreatePool("Drafts", "", smolboard.PermissionUser)
+		if err != nil {
+			t.Fatal("Failed to create pool:", err)
+		}
+
+		// Add the pages in the wrong order.
+		for _, id := range []int64{pages[1], pages[0], pages[2]} {
+			if err := tx.AddPoolPost(pool.ID, id); err != nil {
+				t.Fatal("Failed to add post to pool:", err)
+			}
+		}
+
+		if err := tx.AddPoolPost(pool.ID, pages[0]); err != smolboard.ErrPostAlreadyInPool {
+			t.Fatal("Unexpected error adding a post twice:", err)
+		}
+
+		if err := tx.AddPoolPost(private.ID, pages[0]); err != nil {
+			t.Fatal("Failed to add post to pool:", err)
+		}
+
+		if eq := deep.Equal(poolPosts(t, tx, pool.ID), []int64{pages[1], pages[0], pages[2]}); eq != nil {
+			t.Fatal("Unexpected pool posts:", eq)
+		}
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		var invalid = [][]int64{
+			{pages[0], pages[1]},
+			{pages[0], pages[0], pages[1]},
+			{pages[0], pages[1], 1},
+		}
+
+		for _, order := range invalid {
+			if err := tx.ReorderPool(pool.ID, order); err != smolboard.ErrPoolOrderMismatch {
+				t.Fatalf("Unexpected error reordering with %v: %v", order, err)
+			}
+		}
+
+		if err := tx.ReorderPool(pool.ID, pages[:]); err != nil {
+			t.Fatal("Failed to reorder pool:", err)
+		}
+
+		if eq := deep.Equal(poolPosts(t, tx, pool.ID), pages[:]); eq != nil {
+			t.Fatal("Unexpected pool posts after reordering:", eq)
+		}
+
+		if err := tx.RemovePoolPost(pool.ID, pages[1]); err != nil {
+			t.Fatal("Failed to remove post from pool:", err)
+		}
+
+		if err := tx.RemovePoolPost(pool.ID, pages[1]); err != smolboard.ErrPostNotInPool {
+			t.Fatal("Unexpected error removing a removed post:", err)
+		}
+
+		if err := tx.AddPoolPost(pool.ID, pages[1]); err != nil {
+			t.Fatal("Failed to add post to pool:", err)
+		}
+
+		if eq := deep.Equal(poolPosts(t, tx, pool.ID), []int64{pages[0], pages[2], pages[1]}); eq != nil {
+			t.Fatal("Unexpected pool posts after re-adding:", eq)
+		}
+	})
+
+	t.Run("Other", func(t *testing.T) {
+		tx := testBeginTx(t, d, other.AuthToken)
+
+		if err := tx.AddPoolPost(pool.ID, pages[1]); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error changing another user's pool:", err)
+		}
+
+		var name = "Mine"
+		if err := tx.EditPool(pool.ID, smolboard.PoolEdit{Name: &name}); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error editing another user's pool:", err)
+		}
+
+		p, err := tx.Post(pages[0])
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		if eq := deep.Equal(p.Pools, []smolboard.Pool{*pool, *private}); eq != nil {
+			t.Fatal("Unexpected pools of post:", eq)
+		}
+
+		l, err := tx.Pools(25, 0)
+		if err != nil {
+			t.Fatal("Failed to list pools:", err)
+		}
+
+		if eq := deep.Equal(l.Pools, []smolboard.Pool{*private, *pool}); eq != nil {
+			t.Fatal("Unexpected pools:", eq)
+		}
+
+		s, err := tx.PostSearch("pool:"+strconv.FormatInt(pool.ID, 10), smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search pool:", err)
+		}
+
+		if s.Total != 3 {
+			t.Fatal("Unexpected total searching pool:", s.Total)
+		}
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		var perm = smolboard.PermissionTrusted
+		if err := tx.EditPool(private.ID, smolboard.PoolEdit{Permission: &perm}); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error raising pool's permission over own:", err)
+		}
+
+		perm = smolboard.PermissionUser
+		var desc = "Sketches."
+
+		err := tx.EditPool(pool.ID, smolboard.PoolEdit{Description: &desc, Permission: &perm})
+		if err != nil {
+			t.Fatal("Failed to edit pool:", err)
+		}
+
+		p, err := tx.Pool(pool.ID)
+		if err != nil {
+			t.Fatal("Failed to get pool:", err)
+		}
+
+		if p.Name != "Comic" || p.Description != desc || p.Permission != perm {
+			t.Fatalf("Unexpected pool after editing: %#v", p.Pool)
+		}
+	})
+
+	t.Run("Guest", func(t *testing.T) {
+		err := d.AcquireGuest(context.TODO(), func(tx *Transaction) error {
+			if _, err := tx.Pool(pool.ID); err != smolboard.ErrPoolNotFound {
+				t.Error("Unexpected error getting a hidden pool:", err)
+			}
+
+			s, err := tx.PostSearch("pool:"+strconv.FormatInt(pool.ID, 10), smolboard.Cursor{}, 25, 0)
+			if err != nil {
+				return err
+			}
+
+			if len(s.Posts) != 0 {
+				t.Error("Unexpected posts in a hidden pool:", len(s.Posts))
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal("Failed to search pool as guest:", err)
+		}
+	})
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	if err := tx.DeletePool(pool.ID); err != nil {
+		t.Fatal("Failed to delete pool as owner:", err)
+	}
+
+	if _, err := tx.Pool(pool.ID); err != smolboard.ErrPoolNotFound {
+		t.Fatal("Unexpected error getting a deleted pool:", err)
+	}
+
+	// The posts should still be there.
+	if _, err := tx.Post(pages[0]); err != nil {
+		t.Fatal("Failed to get post after deleting its pool:", err)
+	}
+}
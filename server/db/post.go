@@ -1,11 +1,13 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"strings"
+	"time"
 
+	"github.com/diamondburned/smolboard/server/httperr"
 	"github.com/diamondburned/smolboard/smolboard"
-	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
@@ -17,23 +19,36 @@ func NewEmptyPost(ctype string) smolboard.Post {
 	}
 }
 
-// PostSearch parses the query string and returns the searched posts.
-func (d *Transaction) PostSearch(q string, count, page uint) (smolboard.SearchResults, error) {
+// PostSearch parses the query string and returns the searched posts. The page
+// is counted from the cursor in its direction.
+func (d *Transaction) PostSearch(
+	q string, cursor smolboard.Cursor, count, page uint) (smolboard.SearchResults, error) {
+
 	p, err := smolboard.ParsePostQuery(q)
 	if err != nil {
 		return smolboard.NoResults, err
 	}
 
-	return d.posts(p, count, page)
+	return d.posts(p, cursor, count, page)
+}
+
+// PostSearchQuery is similar to PostSearch, but it takes an already parsed
+// query. This is useful for changing the parsed query, such as its order.
+func (d *Transaction) PostSearchQuery(
+	q smolboard.Query, cursor smolboard.Cursor, count, page uint) (smolboard.SearchResults, error) {
+
+	return d.posts(q, cursor, count, page)
 }
 
 // Posts returns the list of posts that's paginated. Count represents the limit
 // for each page and page represents the page offset 0-indexed.
 func (d *Transaction) Posts(count, page uint) (smolboard.SearchResults, error) {
-	return d.posts(smolboard.AllPosts, count, page)
+	return d.posts(smolboard.AllPosts, smolboard.Cursor{}, count, page)
 }
 
-func (d *Transaction) posts(pq smolboard.Query, count, page uint) (smolboard.SearchResults, error) {
+func (d *Transaction) posts(
+	pq smolboard.Query, cursor smolboard.Cursor, count, page uint) (smolboard.SearchResults, error) {
+
 	p, err := d.Permission()
 	if err != nil {
 		return smolboard.NoResults, err
@@ -44,8 +59,12 @@ func (d *Transaction) posts(pq smolboard.Query, count, page uint) (smolboard.Sea
 		return smolboard.NoResults, smolboard.ErrPageCountLimit
 	}
 
+	if cursor.Before != 0 && cursor.After != 0 {
+		return smolboard.NoResults, smolboard.ErrCursorHasBoth
+	}
+
 	var results = smolboard.SearchResults{
-		Posts: make([]smolboard.Post, 0, count),
+		Posts: make([]smolboard.Post, 0, count+1),
 	}
 
 	// Get the user if any.
@@ -57,60 +76,73 @@ func (d *Transaction) posts(pq smolboard.Query, count, page uint) (smolboard.Sea
 		results.User = u
 	}
 
-	// The worst-case benchmark showed this sqlx.In query building step to take
-	// roughly 51 microseconds (us) (outdated).
+	where, err := d.searchWhere(pq, p)
+	if err != nil {
+		return smolboard.NoResults, err
+	}
 
-	// Separate the query header to conditionally
-	header := strings.Builder{}
-	header.WriteString("FROM posts ")
+	// Random orders without a seed would always give the same order, so pick
+	// one for the caller to page through with.
+	if pq.Order.By == smolboard.OrderRandom {
+		if pq.Order.Seed == 0 {
+			pq.Order.Seed = time.Now().UnixNano()
+		}
+		results.Seed = pq.Order.Seed
+	}
 
-	// This query does an explicit OR check to make sure the poster can
-	// always see their posts regardless of the post's permission.
-	footer := strings.Builder{}
-	footer.WriteString("WHERE (posts.poster = ? OR posts.permission <= ?) ")
+	order, err := newPostOrder(pq.Order)
+	if err != nil {
+		return smolboard.NoResults, err
+	}
 
-	// muh optimization
-	footerArgs := make([]interface{}, 2, 6)
-	footerArgs[0] = d.Session.Username
-	footerArgs[1] = p
+	// Orders with keys compare against the cursor post's key, which doesn't
+	// exist if the post was deleted, so nothing would be listed.
+	if id := cursor.Before | cursor.After; id != 0 && order.key != nil {
+		var exists bool
 
-	if pq.Poster != "" {
-		footer.WriteString("AND posts.poster = ? ")
-		footerArgs = append(footerArgs, pq.Poster)
-	}
+		err := d.QueryRow("SELECT EXISTS (SELECT 1 FROM posts WHERE id = ?)", id).Scan(&exists)
+		if err != nil {
+			return smolboard.NoResults, errors.Wrap(err, "Failed to check cursor post")
+		}
 
-	if len(pq.Tags) > 0 {
-		// In order to search for tags, we'll need to join these tables.
-		header.WriteString("JOIN posttags ON posttags.postid = posts.id ")
-		// Query using the above joins. The HAVING COUNT query is needed to only
-		// show posts with all the tags searched.
-		footer.WriteString(`
-			AND posttags.tagname IN (?)
-			GROUP BY posts.id HAVING COUNT(posttags.tagname) = ? `)
-		// There used to be a GROUP BY here. However, the GROUP BY messes up the
-		// COUNT and SUM functions.
-		footerArgs = append(footerArgs, pq.Tags, len(pq.Tags))
+		if !exists {
+			return smolboard.NoResults, smolboard.ErrPostNotFound
+		}
 	}
 
 	// Build the paginated query.
 	query := strings.Builder{}
 	query.WriteString("SELECT posts.* ")
-	query.WriteString(header.String())
-	query.WriteString(footer.String())
-	// Sort the ID decrementally, which is latest first.
-	query.WriteString("ORDER BY posts.id DESC ")
+	query.WriteString(where.String())
+	queryargs := append([]interface{}{}, where.args...)
 
-	// Append the final pagination query. SQL is dumb and wants LIMIT (offset),
-	// (count) for some reason.
-	query.WriteString("LIMIT ?, ?")
-	queryargs := append(footerArgs, count*page, count)
+	switch {
+	case cursor.After != 0:
+		query.WriteString(" AND ")
+		query.WriteString(order.after())
+		queryargs = append(queryargs, cursor.After)
 
-	qstring, inargs, err := sqlx.In(query.String(), queryargs...)
-	if err != nil {
-		return smolboard.NoResults, errors.Wrap(err, "Failed to construct SQL IN query")
+	case cursor.Before != 0:
+		// Query the posts before the cursor backwards, then reverse them back
+		// after.
+		order = order.reverse()
+
+		query.WriteString(" AND ")
+		query.WriteString(order.after())
+		queryargs = append(queryargs, cursor.Before)
 	}
 
-	q, err := d.Queryx(qstring, inargs...)
+	query.WriteByte(' ')
+	query.WriteString(order.clause())
+	query.WriteByte(' ')
+
+	// Append the final pagination query. SQL is dumb and wants LIMIT (offset),
+	// (count) for some reason. Query an extra post to know if there's a next
+	// page.
+	query.WriteString("LIMIT ?, ?")
+	queryargs = append(queryargs, count*page, count+1)
+
+	q, err := d.Queryx(query.String(), queryargs...)
 	if err != nil {
 		return smolboard.NoResults, errors.Wrap(err, "Failed to query for posts")
 	}
@@ -127,34 +159,78 @@ func (d *Transaction) posts(pq smolboard.Query, count, page uint) (smolboard.Sea
 		results.Posts = append(results.Posts, p)
 	}
 
+	var hasMore = uint(len(results.Posts)) > count
+	if hasMore {
+		results.Posts = results.Posts[:count]
+	}
+
 	// Save the sum count query up if there's no posts found.
-	if len(results.Posts) > 0 {
-		// Build the sum count query.
-		countq := strings.Builder{}
-		countq.WriteString(`
-			SELECT SUM(postcount), SUM(postsize) FROM (
-				SELECT
-					COUNT(DISTINCT posts.id) AS postcount,
-					SUM(posts.size) * COUNT(DISTINCT posts.id) / COUNT(posts.id) AS postsize `)
-		countq.WriteString(header.String())
-		countq.WriteString(footer.String())
-		countq.WriteString(")")
-
-		cstring, inargs, err := sqlx.In(countq.String(), footerArgs...)
-		if err != nil {
-			return smolboard.NoResults, errors.Wrap(err, "Failed to construct SQL IN query")
-		}
+	if len(results.Posts) == 0 {
+		return results, nil
+	}
+
+	countq := "SELECT COUNT(1), COALESCE(SUM(posts.size), 0) " + where.String()
+
+	if err := d.QueryRow(countq, where.args...).Scan(&results.Total, &results.Sizes); err != nil {
+		return smolboard.NoResults, errors.Wrap(err, "Failed to scan total posts found")
+	}
+
+	var hasNext, hasPrev bool
 
-		if err := d.QueryRow(cstring, inargs...).Scan(&results.Total, &results.Sizes); err != nil {
-			return smolboard.NoResults, errors.Wrap(err, "Failed to scan total posts found")
+	if cursor.Before != 0 {
+		for i, j := 0, len(results.Posts)-1; i < j; i, j = i+1, j-1 {
+			results.Posts[i], results.Posts[j] = results.Posts[j], results.Posts[i]
 		}
+
+		// The cursor post is after these posts.
+		hasNext = true
+		hasPrev = hasMore
+	} else {
+		hasNext = hasMore
+		hasPrev = cursor.After != 0 || page > 0
+	}
+
+	if hasNext {
+		results.NextCursor = results.Posts[len(results.Posts)-1].ID
+	}
+	if hasPrev {
+		results.PrevCursor = results.Posts[0].ID
 	}
 
 	return results, nil
 }
 
+// searchWhere builds the FROM and WHERE clauses of the posts matching the
+// query that are visible to the user with the given permission.
+func (d *Transaction) searchWhere(pq smolboard.Query, p smolboard.Permission) (queryBuilder, error) {
+	// This query does an explicit OR check to make sure the poster can
+	// always see their posts regardless of the post's permission.
+	where := queryBuilder{username: d.Session.Username, permission: p}
+	where.WriteString("FROM posts WHERE (posts.poster = ? OR posts.permission <= ?) ")
+	where.args = []interface{}{d.Session.Username, p}
+
+	// Hide posts with blacklisted tags, unless they're the user's own.
+	if d.blacklisted() {
+		where.WriteString("AND (posts.poster IS ? OR NOT " + sqlBlacklisted("posts.id") + ") ")
+		where.args = append(where.args, d.Session.Username, d.Session.Username)
+	}
+
+	if pq.Expr != nil {
+		// Every term is compiled into its own condition, so there's no need to
+		// join nor group, which keeps the COUNT and SUM functions correct.
+		where.WriteString("AND ")
+
+		if err := where.expr(pq.Expr); err != nil {
+			return where, errors.Wrap(err, "Failed to build search query")
+		}
+	}
+
+	return where, nil
+}
+
 // PostQuickGet gets a normal post instance. This function is used primarily
-// internally, but exported for local use.
+// internally, but exported for local use. Like Post, it returns
+// ErrPostBlacklisted for posts hidden by the user's blacklist.
 func (d *Transaction) PostQuickGet(id int64) (*smolboard.Post, error) {
 	// Fast path: ignore invalid IDs.
 	if id == 0 {
@@ -181,11 +257,16 @@ func (d *Transaction) PostQuickGet(id int64) (*smolboard.Post, error) {
 		return nil, errors.Wrap(err, "Failed to check post")
 	}
 
+	if err := d.checkBlacklist(&post); err != nil {
+		return nil, err
+	}
+
 	return &post, nil
 }
 
 // Post returns a single post with the ID. It returns a post not found error if
-// the post is not found or the user does not have permission to see the post.
+// the post is not found or the user does not have permission to see the post,
+// and a blacklisted error if the post has a tag in the user's blacklist.
 func (d *Transaction) Post(id int64) (*smolboard.PostExtended, error) {
 	// Fast path: ignore invalid IDs.
 	if id == 0 {
@@ -214,6 +295,10 @@ func (d *Transaction) Post(id int64) (*smolboard.PostExtended, error) {
 		return nil, errors.Wrap(err, "Failed to get post")
 	}
 
+	if err := d.checkBlacklist(&post); err != nil {
+		return nil, err
+	}
+
 	var poster *smolboard.UserPart
 	if post.Poster != nil {
 		poster, err = d.User(*post.Poster)
@@ -229,10 +314,9 @@ func (d *Transaction) Post(id int64) (*smolboard.PostExtended, error) {
 	}
 
 	t, err := d.Queryx(`
-		SELECT COUNT(1), posttags.tagname FROM posttags
-		JOIN   posttags AS posttags2 ON posttags2.tagname = posttags.tagname
-		WHERE  posttags2.postid = ?
-		GROUP  BY posttags.tagname
+		SELECT tags.count, posttags.tagname FROM posttags
+		JOIN   tags ON tags.name = posttags.tagname
+		WHERE  posttags.postid = ?
 		ORDER  BY posttags.tagname ASC`,
 		id,
 	)
@@ -257,9 +341,78 @@ func (d *Transaction) Post(id int64) (*smolboard.PostExtended, error) {
 		postEx.Tags = append(postEx.Tags, tag)
 	}
 
+	postEx.TagGroups, err = d.groupTags(postEx.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	postEx.Favorites, postEx.Favorited, err = d.favorites(id)
+	if err != nil {
+		return nil, err
+	}
+
+	postEx.Vote, err = d.vote(id)
+	if err != nil {
+		return nil, err
+	}
+
+	postEx.Pools, err = d.postPools(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if post.ParentID != nil {
+		postEx.Parent, err = d.PostQuickGet(*post.ParentID)
+		// Leave the parent out if the user cannot see it.
+		if err != nil && !errors.Is(err, smolboard.ErrPostNotFound) &&
+			!errors.Is(err, smolboard.ErrPostBlacklisted) {
+			return nil, err
+		}
+	}
+
+	postEx.Children, err = d.postChildren(id, p)
+	if err != nil {
+		return nil, err
+	}
+
 	return &postEx, nil
 }
 
+// postChildren returns the first MaxPostChildren children of the post that are
+// visible to the user with the given permission.
+func (d *Transaction) postChildren(id int64, p smolboard.Permission) ([]smolboard.Post, error) {
+	var query = "SELECT * FROM posts WHERE parent = ? AND (poster = ? OR permission <= ?)"
+	var args = []interface{}{id, d.Session.Username, p}
+
+	if d.blacklisted() {
+		query += " AND (poster IS ? OR NOT " + sqlBlacklisted("posts.id") + ")"
+		args = append(args, d.Session.Username, d.Session.Username)
+	}
+
+	args = append(args, smolboard.MaxPostChildren)
+
+	q, err := d.Queryx(query+" ORDER BY id ASC LIMIT ?", args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query children")
+	}
+
+	defer q.Close()
+
+	var children = []smolboard.Post{}
+
+	for q.Next() {
+		var post smolboard.Post
+
+		if err := q.StructScan(&post); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan child")
+		}
+
+		children = append(children, post)
+	}
+
+	return children, q.Err()
+}
+
 func (d *Transaction) SavePost(post *smolboard.Post) error {
 	if post.ID == 0 || post.ContentType == "" || post.Size == 0 {
 		return errors.New("cannot use empty post")
@@ -269,12 +422,40 @@ func (d *Transaction) SavePost(post *smolboard.Post) error {
 		return err
 	}
 
+	err := smolboard.PostEditIsValid(smolboard.PostEdit{
+		Title:       &post.Title,
+		Description: &post.Description,
+		Source:      &post.Source,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reject the post if the same content was already uploaded. The existing
+	// post is checked regardless of whether the user can see it, but its ID is
+	// only given if they can.
+	if post.Hash != "" {
+		var existing int64
+
+		err := d.QueryRow("SELECT id FROM posts WHERE hash = ? LIMIT 1", post.Hash).Scan(&existing)
+		if err == nil {
+			return d.duplicatePost(existing)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrap(err, "Failed to check for duplicates")
+		}
+	}
+
 	// Set the post's username to the current user.
 	post.SetPoster(d.Session.Username)
 
-	_, err := d.Exec(
-		"INSERT INTO posts VALUES (?, ?, ?, ?, ?, ?)",
+	_, err = d.Exec(
+		`INSERT INTO posts (
+			id, size, poster, contenttype, permission, attributes,
+			title, description, source, hash, phash
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		post.ID, post.Size, post.Poster, post.ContentType, post.Permission, post.Attributes,
+		post.Title, post.Description, post.Source, post.Hash, post.PHash,
 	)
 
 	if err != nil && errIsConstraint(err) {
@@ -284,6 +465,83 @@ func (d *Transaction) SavePost(post *smolboard.Post) error {
 	return err
 }
 
+// duplicatePost returns the error for a post with the same content as the
+// existing post. Hidden posts are left out of the error so they aren't leaked.
+func (d *Transaction) duplicatePost(existing int64) error {
+	_, err := d.PostQuickGet(existing)
+	switch {
+	case err == nil, errors.Is(err, smolboard.ErrPostBlacklisted):
+		return smolboard.ErrDuplicatePost{PostID: existing}
+	case errors.Is(err, smolboard.ErrPostNotFound):
+		return smolboard.ErrDuplicatePost{}
+	default:
+		return err
+	}
+}
+
+// HashPosts initializes the database then hashes the content of the posts
+// uploaded before it was hashed, so their duplicates are rejected as well. The
+// hash function returns the hash of the post's file; posts it returns an empty
+// hash for are skipped. The number of posts hashed is returned.
+func HashPosts(config DBConfig, hash func(smolboard.Post) (string, error)) (n int, err error) {
+	d, err := NewDatabase(config)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to initialize database")
+	}
+	defer d.Close()
+
+	err = d.AcquireGuest(context.Background(), func(tx *Transaction) error {
+		n, err = tx.hashPosts(hash)
+		return err
+	})
+
+	return n, err
+}
+
+func (d *Transaction) hashPosts(hash func(smolboard.Post) (string, error)) (int, error) {
+	r, err := d.Queryx("SELECT * FROM posts WHERE hash = ''")
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to query posts without hashes")
+	}
+
+	// Read all posts first, since they're updated one by one.
+	var posts []smolboard.Post
+
+	for r.Next() {
+		var p smolboard.Post
+
+		if err := r.StructScan(&p); err != nil {
+			r.Close()
+			return 0, errors.Wrap(err, "Failed to scan post")
+		}
+
+		posts = append(posts, p)
+	}
+
+	r.Close()
+
+	var n int
+
+	for _, post := range posts {
+		h, err := hash(post)
+		if err != nil {
+			return n, errors.Wrapf(err, "Failed to hash post %d", post.ID)
+		}
+
+		if h == "" {
+			continue
+		}
+
+		if _, err := d.Exec("UPDATE posts SET hash = ? WHERE id = ?", h, post.ID); err != nil {
+			return n, errors.Wrapf(err, "Failed to set hash of post %d", post.ID)
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
 // canChangePost returns an error if the user cannot change this post. This
 // includes deleting and tagging.
 func (d *Transaction) canChangePost(postID int64) error {
@@ -317,6 +575,69 @@ func (d *Transaction) DeletePost(id int64) error {
 	return wrapPostErr(r, err, "Failed to execute delete")
 }
 
+// EditPost changes the post's title, description, source or parent. Only the
+// poster or an administrator can do this. The parent must be a post that the
+// user can see, and it cannot be the post itself or any of its descendants.
+func (d *Transaction) EditPost(id int64, e smolboard.PostEdit) error {
+	if err := smolboard.PostEditIsValid(e); err != nil {
+		return err
+	}
+
+	if err := d.canChangePost(id); err != nil {
+		return err
+	}
+
+	if e.Parent != nil && *e.Parent != 0 {
+		if err := d.canSetParent(id, *e.Parent); err != nil {
+			return err
+		}
+	}
+
+	r, err := d.Exec(
+		`UPDATE posts SET
+			title       = COALESCE(?, title),
+			description = COALESCE(?, description),
+			source      = COALESCE(?, source),
+			parent      = CASE WHEN ? IS NULL THEN parent ELSE NULLIF(?, 0) END
+		WHERE id = ?`,
+		e.Title, e.Description, e.Source, e.Parent, e.Parent, id,
+	)
+	return wrapPostErr(r, err, "Failed to execute update")
+}
+
+// canSetParent returns an error if the parent cannot be set as the post's
+// parent.
+func (d *Transaction) canSetParent(id, parent int64) error {
+	if _, err := d.PostQuickGet(parent); err != nil {
+		return err
+	}
+
+	// Walk up from the new parent. The post would be its own ancestor if it's
+	// found along the way. UNION stops the walk on existing cycles.
+	var cycle bool
+
+	err := d.QueryRow(`
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT ?
+			UNION
+			SELECT posts.parent FROM posts
+			JOIN   ancestors ON ancestors.id = posts.id
+			WHERE  posts.parent IS NOT NULL
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ?)`,
+		parent, id,
+	).Scan(&cycle)
+	if err != nil {
+		return errors.Wrap(err, "Failed to scan ancestors")
+	}
+
+	if cycle {
+		return smolboard.ErrParentCycle
+	}
+
+	return nil
+}
+
 // SetPostPermission sets the post's permission. The current user can set the
 // post's permission to as high as their own if this is their post or if the
 // user is an administrator.
@@ -340,27 +661,151 @@ func (d *Transaction) SetPostPermission(id int64, target smolboard.Permission) e
 	return wrapPostErr(r, err, "Failed to execute update")
 }
 
+// EditPosts applies the batch edit to each post. Each post is changed
+// atomically, so a post that fails to be changed is left untouched. These
+// failures are returned in the results instead of as the error, which is only
+// returned if the whole batch fails.
+func (d *Transaction) EditPosts(b smolboard.PostBatch) ([]smolboard.PostBatchResult, error) {
+	switch {
+	case len(b.PostIDs) == 0:
+		return nil, smolboard.ErrBatchEmpty
+	case len(b.PostIDs) > smolboard.MaxBatchPosts:
+		return nil, smolboard.ErrBatchTooLarge
+	}
+
+	for _, tag := range b.AddTags {
+		if err := validTag(tag); err != nil {
+			return nil, err
+		}
+	}
+	for _, tag := range b.RemoveTags {
+		if err := validTagName(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.Permission != nil && !b.Permission.IsValid() {
+		return nil, smolboard.ErrInvalidPermission
+	}
+
+	var results = make([]smolboard.PostBatchResult, len(b.PostIDs))
+
+	for i, id := range b.PostIDs {
+		results[i].PostID = id
+
+		// Use a savepoint to undo the changes of only this post on failure.
+		if _, err := d.Exec("SAVEPOINT editpost"); err != nil {
+			return nil, errors.Wrap(err, "Failed to create savepoint")
+		}
+
+		if err := d.editPost(id, b); err != nil {
+			// Errors that aren't the user's fault fail the whole batch.
+			if httperr.ErrCode(err) >= 500 {
+				return nil, err
+			}
+
+			if _, err := d.Exec("ROLLBACK TO editpost"); err != nil {
+				return nil, errors.Wrap(err, "Failed to roll back to savepoint")
+			}
+
+			results[i].Error = err.Error()
+		}
+
+		if _, err := d.Exec("RELEASE editpost"); err != nil {
+			return nil, errors.Wrap(err, "Failed to release savepoint")
+		}
+	}
+
+	return results, nil
+}
+
+func (d *Transaction) editPost(id int64, b smolboard.PostBatch) error {
+	if err := d.canChangePost(id); err != nil {
+		return err
+	}
+
+	for _, tag := range b.AddTags {
+		if err := d.TagPost(id, tag); err != nil && err != smolboard.ErrTagAlreadyAdded {
+			return err
+		}
+	}
+
+	for _, tag := range b.RemoveTags {
+		// The post is known to exist, so this error means it doesn't have the
+		// tag.
+		if err := d.UntagPost(id, tag); err != nil && err != smolboard.ErrPostNotFound {
+			return err
+		}
+	}
+
+	if b.Permission != nil {
+		if err := d.SetPostPermission(id, *b.Permission); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validTag validates a tag that's about to be added to posts.
 func validTag(tag string) error {
 	return smolboard.TagIsValid(tag)
 }
 
-// SearchTag searches for tags and returns at max 25 tags. It returns only the
-// count and name.
+// validTagName validates a tag name that refers to an existing tag, which may
+// have been made before its name was reserved.
+func validTagName(tag string) error {
+	return smolboard.TagNameIsValid(tag)
+}
+
+// searchTagCandidates is the number of most used tags with visible posts to
+// count the visible posts of when searching.
+const searchTagCandidates = 100
+
+// likeEscaper escapes the wildcards in a LIKE pattern with a backslash.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchTag searches for tags that start with the given string and returns at
+// max 25 tags with their count. An empty string returns the most used tags.
+// Only tags on posts visible to the current user are counted, so hidden posts
+// don't leak their tags.
 func (d *Transaction) SearchTag(part string) ([]smolboard.PostTag, error) {
 	// A partial tag should still be valid.
-	if err := validTag(part); err != nil {
+	if part != "" {
+		if err := validTagName(part); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := d.Permission()
+	if err != nil {
 		return nil, err
 	}
 
-	// SQL queries like these aren't the brightest idea.
+	// Only the most used tags are counted again for their visible posts, which
+	// is much cheaper than counting all tags. Tags without any visible posts
+	// are left out of the candidates, so they can't push the visible ones out.
 	t, err := d.Queryx(`
-		SELECT COUNT(1), posttags.tagname FROM posttags
-		JOIN   posttags AS posttags2 ON posttags2.tagname = posttags.tagname
-		WHERE  posttags2.tagname LIKE ? || '%'
+		WITH candidates AS (
+			SELECT name FROM tags
+			WHERE  name LIKE ? || '%' ESCAPE '\' AND count > 0 AND EXISTS (
+				SELECT 1 FROM posttags
+				JOIN   posts ON posts.id = posttags.postid
+				WHERE  posttags.tagname = tags.name
+				  AND  (posts.poster = ? OR posts.permission <= ?)
+			)
+			ORDER  BY count DESC
+			LIMIT  ?
+		)
+		SELECT COUNT(1), posttags.tagname FROM candidates
+		JOIN   posttags ON posttags.tagname = candidates.name
+		JOIN   posts ON posts.id = posttags.postid
+		WHERE  posts.poster = ? OR posts.permission <= ?
 		GROUP  BY posttags.tagname
-		ORDER  BY COUNT(1) DESC
+		ORDER  BY COUNT(1) DESC, posttags.tagname ASC
 		LIMIT  25`,
-		part,
+		likeEscaper.Replace(part), d.Session.Username, p, searchTagCandidates,
+		d.Session.Username, p,
 	)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to query tags")
@@ -383,6 +828,10 @@ func (d *Transaction) SearchTag(part string) ([]smolboard.PostTag, error) {
 	return tags, nil
 }
 
+// TagPost tags the post. If the tag is an alias, then the post is tagged with
+// the canonical tag instead. The post is also tagged with all tags implied by
+// the tag that it doesn't have yet. Restricted tags can only be added by users
+// with enough permission.
 func (d *Transaction) TagPost(postID int64, tag string) error {
 	if err := validTag(tag); err != nil {
 		return err
@@ -392,17 +841,40 @@ func (d *Transaction) TagPost(postID int64, tag string) error {
 		return err
 	}
 
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	if err := d.canApplyTag(tag); err != nil {
+		return err
+	}
+
+	if err := d.insertPostTag(postID, tag); err != nil {
+		return err
+	}
+
+	return d.tagImplied(postID, tag)
+}
+
+// insertPostTag adds the tag to the post as-is and logs the change.
+func (d *Transaction) insertPostTag(postID int64, tag string) error {
 	r, err := d.Exec("INSERT INTO posttags VALUES (?, ?)", postID, tag)
 	if err != nil {
 		if errIsConstraint(err) {
 			return smolboard.ErrTagAlreadyAdded
 		}
 	}
-	return wrapPostErr(r, err, "Failed to execute insert tag")
+	if err := wrapPostErr(r, err, "Failed to execute insert tag"); err != nil {
+		return err
+	}
+
+	return d.logTagEdit(postID, tag, smolboard.TagEditAdd)
 }
 
+// UntagPost untags the post. Aliases are resolved the same way as TagPost.
 func (d *Transaction) UntagPost(postID int64, tag string) error {
-	if err := validTag(tag); err != nil {
+	if err := validTagName(tag); err != nil {
 		return err
 	}
 
@@ -410,12 +882,29 @@ func (d *Transaction) UntagPost(postID int64, tag string) error {
 		return err
 	}
 
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	if err := d.canApplyTag(tag); err != nil {
+		return err
+	}
+
+	return d.deletePostTag(postID, tag)
+}
+
+// deletePostTag removes the tag from the post as-is and logs the change.
+func (d *Transaction) deletePostTag(postID int64, tag string) error {
 	r, err := d.Exec(
 		"DELETE FROM posttags WHERE postid = ? AND tagname = ?",
 		postID, tag,
 	)
+	if err := wrapPostErr(r, err, "Failed to execute delete tag"); err != nil {
+		return err
+	}
 
-	return wrapPostErr(r, err, "Failed to execute delete tag")
+	return d.logTagEdit(postID, tag, smolboard.TagEditRemove)
 }
 
 func wrapPostErr(r sql.Result, err error, wrap string) error {
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/diamondburned/smolboard/smolboard"
 	"github.com/go-test/deep"
@@ -238,6 +240,425 @@ func TestPostNullOwner(t *testing.T) {
 	})
 }
 
+func TestEditPosts(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionTrusted)
+
+	var ownerPost, userPost int64
+
+	t.Run("Setup", func(t *testing.T) {
+		ownerPost = testNewTaggedPost(t, testBeginTx(t, d, owner.AuthToken), "dog")
+	})
+
+	t.Run("SetupUser", func(t *testing.T) {
+		userPost = testNewTaggedPost(t, testBeginTx(t, d, user.AuthToken), "dog", "bird")
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		var perm = smolboard.PermissionUser
+
+		r, err := tx.EditPosts(smolboard.PostBatch{
+			PostIDs:    []int64{ownerPost, userPost, 1},
+			AddTags:    []string{"cat", "bird"},
+			RemoveTags: []string{"dog", "fish"},
+			Permission: &perm,
+		})
+		if err != nil {
+			t.Fatal("Failed to edit posts:", err)
+		}
+
+		expect := []smolboard.PostBatchResult{
+			{PostID: ownerPost, Error: smolboard.ErrActionNotPermitted.Error()},
+			{PostID: userPost},
+			{PostID: 1, Error: smolboard.ErrPostNotFound.Error()},
+		}
+
+		if eq := deep.Equal(r, expect); eq != nil {
+			t.Fatal("Unexpected results:", eq)
+		}
+
+		if eq := deep.Equal(testPostTags(t, tx, userPost), []string{"bird", "cat"}); eq != nil {
+			t.Fatal("Unexpected tags after editing:", eq)
+		}
+
+		p, err := tx.Post(userPost)
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		if p.Permission != perm {
+			t.Fatal("Unexpected permission after editing:", p.Permission)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		// The permission is too high, so the tag shouldn't be added either.
+		var perm = smolboard.PermissionOwner
+
+		r, err := tx.EditPosts(smolboard.PostBatch{
+			PostIDs:    []int64{userPost},
+			AddTags:    []string{"fish"},
+			Permission: &perm,
+		})
+		if err != nil {
+			t.Fatal("Failed to edit posts:", err)
+		}
+
+		if len(r) != 1 || r[0].Error == "" {
+			t.Fatalf("Unexpected results: %#v", r)
+		}
+
+		if eq := deep.Equal(testPostTags(t, tx, userPost), []string{"bird", "cat"}); eq != nil {
+			t.Fatal("Unexpected tags after failing:", eq)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		if _, err := tx.EditPosts(smolboard.PostBatch{}); err != smolboard.ErrBatchEmpty {
+			t.Fatal("Unexpected error editing no posts:", err)
+		}
+
+		_, err := tx.EditPosts(smolboard.PostBatch{
+			PostIDs: []int64{userPost},
+			AddTags: []string{"-x"},
+		})
+		if err != smolboard.ErrIllegalTag {
+			t.Fatal("Unexpected error editing with an illegal tag:", err)
+		}
+	})
+}
+
+func TestEditPost(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionTrusted)
+
+	var ownerPost, userPost smolboard.Post
+
+	t.Run("Setup", func(t *testing.T) {
+		ownerPost = NewEmptyPost("image/png")
+		ownerPost.Size = 1
+		ownerPost.Title = "Sunset at the beach"
+		ownerPost.Source = "https://example.com/sunset"
+
+		if err := testBeginTx(t, d, owner.AuthToken).SavePost(&ownerPost); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+	})
+
+	t.Run("SetupUser", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		userPost = NewEmptyPost("image/png")
+		userPost.Size = 1
+		userPost.Source = "javascript:alert(1)"
+
+		if err := tx.SavePost(&userPost); err != smolboard.ErrIllegalSource {
+			t.Fatal("Unexpected error saving post with illegal source:", err)
+		}
+
+		userPost.Source = ""
+		userPost.Description = "A cat sleeping on the beach."
+
+		if err := tx.SavePost(&userPost); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		var title = "Sleepy cat"
+
+		if err := tx.EditPost(ownerPost.ID, smolboard.PostEdit{Title: &title}); err == nil {
+			t.Fatal("Unexpected success editing the owner's post")
+		}
+
+		if err := tx.EditPost(userPost.ID, smolboard.PostEdit{Title: &title}); err != nil {
+			t.Fatal("Failed to edit post:", err)
+		}
+
+		var long = strings.Repeat("a", smolboard.MaxPostTitleLen+1)
+
+		err := tx.EditPost(userPost.ID, smolboard.PostEdit{Title: &long})
+		if err != smolboard.ErrPostTitleTooLong {
+			t.Fatal("Unexpected error editing with a long title:", err)
+		}
+
+		p, err := tx.Post(userPost.ID)
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		// The description should be left unchanged.
+		if p.Title != title || p.Description != userPost.Description {
+			t.Fatalf("Unexpected post after editing: %#v", p.Post)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		var tests = []struct {
+			query string
+			posts []int64
+		}{
+			{"text:beach", []int64{userPost.ID, ownerPost.ID}},
+			{"text:'sleepy beach'", []int64{userPost.ID}},
+			{"text:sunset", []int64{ownerPost.ID}},
+			{"text:example.com", []int64{ownerPost.ID}},
+			{`text:'"cat'`, []int64{userPost.ID}},
+			{"-text:cat", []int64{ownerPost.ID}},
+		}
+
+		for _, test := range tests {
+			s, err := tx.PostSearch(test.query, smolboard.Cursor{}, 25, 0)
+			if err != nil {
+				t.Fatalf("Failed to search %q: %v", test.query, err)
+			}
+
+			var ids = make([]int64, len(s.Posts))
+			for i, p := range s.Posts {
+				ids[i] = p.ID
+			}
+
+			if eq := deep.Equal(ids, test.posts); eq != nil {
+				t.Fatalf("Unexpected posts searching %q: %v", test.query, eq)
+			}
+		}
+	})
+}
+
+func TestPostHash(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	const hash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	var post = NewEmptyPost("image/png")
+	post.Size = 1
+	post.Hash = hash
+	post.Permission = smolboard.PermissionAdministrator
+
+	t.Run("Setup", func(t *testing.T) {
+		if err := testBeginTx(t, d, owner.AuthToken).SavePost(&post); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		// The duplicate should be found even if the user cannot see it, but
+		// without leaking its ID.
+		dup := NewEmptyPost("image/png")
+		dup.Size = 1
+		dup.Hash = hash
+
+		err := tx.SavePost(&dup)
+		if err != (smolboard.ErrDuplicatePost{}) {
+			t.Fatal("Unexpected error saving duplicate:", err)
+		}
+
+		// Posts without hashes are never duplicates.
+		for i := 0; i < 2; i++ {
+			p := NewEmptyPost("image/png")
+			p.Size = 1
+
+			if err := tx.SavePost(&p); err != nil {
+				t.Fatal("Failed to save post without hash:", err)
+			}
+		}
+	})
+
+	t.Run("DuplicateVisible", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		dup := NewEmptyPost("image/png")
+		dup.Size = 1
+		dup.Hash = hash
+
+		err := tx.SavePost(&dup)
+		if err != (smolboard.ErrDuplicatePost{PostID: post.ID}) {
+			t.Fatal("Unexpected error saving duplicate:", err)
+		}
+	})
+
+	t.Run("Backfill", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		var hashed []int64
+
+		n, err := tx.hashPosts(func(p smolboard.Post) (string, error) {
+			hashed = append(hashed, p.ID)
+			// Skip the second post as if its file was missing.
+			if len(hashed) > 1 {
+				return "", nil
+			}
+			return fmt.Sprintf("%064x", p.ID), nil
+		})
+		if err != nil {
+			t.Fatal("Failed to hash posts:", err)
+		}
+
+		// Only the posts without hashes should be hashed.
+		if n != 1 || len(hashed) != 2 {
+			t.Fatalf("Unexpected posts hashed: %d of %v", n, hashed)
+		}
+
+		p, err := tx.PostQuickGet(hashed[0])
+		if err != nil {
+			t.Fatal("Failed to get hashed post:", err)
+		}
+
+		if p.Hash != fmt.Sprintf("%064x", p.ID) {
+			t.Fatalf("Unexpected hash of post %d: %q", p.ID, p.Hash)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		s, err := tx.PostSearch("hash:"+hash, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search hash:", err)
+		}
+
+		if len(s.Posts) != 1 || s.Posts[0].ID != post.ID || s.Posts[0].Hash != hash {
+			t.Fatalf("Unexpected posts searched: %#v", s.Posts)
+		}
+	})
+}
+
+func TestPostParent(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	var parent, child1, child2 int64
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		parent = testNewTaggedPost(t, tx)
+		child1 = testNewTaggedPost(t, tx)
+		child2 = testNewTaggedPost(t, tx)
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		for _, child := range []int64{child1, child2} {
+			if err := tx.EditPost(child, smolboard.PostEdit{Parent: &parent}); err != nil {
+				t.Fatal("Failed to set parent:", err)
+			}
+		}
+
+		var missing int64 = 1
+
+		err := tx.EditPost(child1, smolboard.PostEdit{Parent: &missing})
+		if err != smolboard.ErrPostNotFound {
+			t.Fatal("Unexpected error setting a missing parent:", err)
+		}
+
+		// Neither the post itself nor its children can be its parent.
+		for _, id := range []int64{parent, child2} {
+			err := tx.EditPost(parent, smolboard.PostEdit{Parent: &id})
+			if err != smolboard.ErrParentCycle {
+				t.Fatalf("Unexpected error setting parent to %d: %v", id, err)
+			}
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		p, err := tx.Post(parent)
+		if err != nil {
+			t.Fatal("Failed to get parent:", err)
+		}
+
+		if p.Parent != nil {
+			t.Fatal("Unexpected parent of parent:", p.Parent)
+		}
+
+		var ids = make([]int64, len(p.Children))
+		for i, c := range p.Children {
+			ids[i] = c.ID
+		}
+
+		if eq := deep.Equal(ids, []int64{child1, child2}); eq != nil {
+			t.Fatal("Unexpected children:", eq)
+		}
+
+		c, err := tx.Post(child1)
+		if err != nil {
+			t.Fatal("Failed to get child:", err)
+		}
+
+		if c.Parent == nil || c.Parent.ID != parent || *c.ParentID != parent {
+			t.Fatalf("Unexpected parent of child: %#v", c.Parent)
+		}
+
+		s, err := tx.PostSearch(fmt.Sprintf("parent:%d", parent), smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search children:", err)
+		}
+
+		if len(s.Posts) != 2 || s.Posts[0].ID != child2 || s.Posts[1].ID != child1 {
+			t.Fatalf("Unexpected children searched: %#v", s.Posts)
+		}
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		var none int64
+
+		if err := tx.EditPost(child2, smolboard.PostEdit{Parent: &none}); err != nil {
+			t.Fatal("Failed to unset parent:", err)
+		}
+
+		c, err := tx.Post(child2)
+		if err != nil {
+			t.Fatal("Failed to get child:", err)
+		}
+
+		if c.ParentID != nil || c.Parent != nil {
+			t.Fatalf("Unexpected parent after unsetting: %#v", c.Parent)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		if err := tx.DeletePost(parent); err != nil {
+			t.Fatal("Failed to delete parent:", err)
+		}
+
+		// The child should be kept without a parent.
+		c, err := tx.Post(child1)
+		if err != nil {
+			t.Fatal("Failed to get child after deleting parent:", err)
+		}
+
+		if c.ParentID != nil {
+			t.Fatal("Unexpected parent after deleting it:", *c.ParentID)
+		}
+	})
+}
+
 func TestPostPermissions(t *testing.T) {
 	for perm, test := range testPermissionSet {
 		p := NewEmptyPost("image/png")
@@ -489,70 +910,237 @@ Search:
 	}
 }
 
-func TestPostSearch(t *testing.T) {
+func TestLegacyTags(t *testing.T) {
 	d := newTestDatabase(t)
 
 	owner := testNewOwner(t, d, "ひめありかわ", "password")
 
 	tx := testBeginTx(t, d, owner.AuthToken)
 
-	p := NewEmptyPost("image/png")
-	p.Size = 1
+	id := testNewTaggedPost(t, tx, "cat")
 
-	if err := tx.SavePost(&p); err != nil {
-		t.Fatal("Failed to save post:", err)
-	}
+	// Tags with names that are reserved now could be added before.
+	var legacy = []string{"-dash", "artist:", "artist:*"}
 
-	// Insert some tags.
-	var tags = _testPostTags
+	for _, tag := range legacy {
+		if err := tx.TagPost(id, tag); err != smolboard.ErrIllegalTag {
+			t.Fatalf("Unexpected error adding legacy tag %q: %v", tag, err)
+		}
 
-	// Add
-	for _, tag := range tags {
-		if err := tx.TagPost(p.ID, tag); err != nil {
-			t.Fatalf("Failed to tag %q post: %v", tag, err)
+		if _, err := tx.Exec("INSERT INTO posttags VALUES (?, ?)", id, tag); err != nil {
+			t.Fatalf("Failed to insert legacy tag %q: %v", tag, err)
 		}
 	}
 
-	sliceEq := func(t *testing.T, s smolboard.SearchResults) {
-		t.Helper()
-
-		if s.Total != 1 {
-			t.Fatal("Invalid total:", s.Total)
+	for _, tag := range legacy {
+		s, err := tx.PostSearch(smolboard.EscapeTag(tag), smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatalf("Failed to search legacy tag %q: %v", tag, err)
 		}
 
-		if s.Sizes != 1 {
-			t.Fatal("Invaid size:", s.Sizes)
+		if len(s.Posts) != 1 || s.Posts[0].ID != id {
+			t.Fatalf("Unexpected posts found with legacy tag %q: %#v", tag, s.Posts)
 		}
+	}
 
-		if len(s.Posts) != 1 {
-			t.Fatal("Invalid posts found with valid search query:", s)
-		}
+	if err := tx.RenameTag("-dash", "dash"); err != nil {
+		t.Fatal("Failed to rename legacy tag:", err)
+	}
 
-		if eq := deep.Equal(p, s.Posts[0]); eq != nil {
-			t.Fatal("Returned post is different:", eq)
+	for _, tag := range legacy[1:] {
+		if err := tx.UntagPost(id, tag); err != nil {
+			t.Fatalf("Failed to untag legacy tag %q: %v", tag, err)
 		}
 	}
 
-	t.Run("Author", func(t *testing.T) {
-		s, err := tx.PostSearch("@ひめありかわ", 25, 0)
-		if err != nil {
-			t.Fatal("Failed to search:", err)
-		}
+	tags := testPostTags(t, tx, id)
 
-		sliceEq(t, s)
-	})
+	if eq := deep.Equal(tags, []string{"cat", "dash"}); eq != nil {
+		t.Fatal("Unexpected tags after cleaning up legacy tags:", eq)
+	}
+}
 
-	t.Run("Tags", func(t *testing.T) {
-		s, err := tx.PostSearch(`"otoko no ko" blush skirt`, 25, 0)
-		if err != nil {
-			t.Fatal("Failed to search:", err)
-		}
+func TestSearchTagVisibility(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	s := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		for _, perm := range []smolboard.Permission{
+			smolboard.PermissionGuest,
+			smolboard.PermissionAdministrator,
+			smolboard.PermissionAdministrator,
+		} {
+			p := NewEmptyPost("image/png")
+			p.Size = 1
+			p.Permission = perm
+
+			if err := tx.SavePost(&p); err != nil {
+				t.Fatal("Failed to save post:", err)
+			}
+
+			var tag = "public_tag"
+			if perm == smolboard.PermissionAdministrator {
+				tag = "private_tag"
+			}
+
+			if err := tx.TagPost(p.ID, tag); err != nil {
+				t.Fatal("Failed to tag post:", err)
+			}
+		}
+	})
+
+	var expect = []smolboard.PostTag{
+		{TagName: "private_tag", Count: 2},
+		{TagName: "public_tag", Count: 1},
+	}
+
+	t.Run("Owner", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		r, err := tx.SearchTag("p")
+		if err != nil {
+			t.Fatal("Failed to search tags:", err)
+		}
+
+		if eq := deep.Equal(r, expect); eq != nil {
+			t.Fatal("Unexpected tags searched:", eq)
+		}
+	})
+
+	t.Run("User", func(t *testing.T) {
+		tx := testBeginTx(t, d, s.AuthToken)
+
+		var tests = map[string][]smolboard.PostTag{
+			"":        expect[1:],
+			"p":       expect[1:],
+			"public_": expect[1:],
+			// Wildcards should be matched literally.
+			"publi__": {},
+			"public%": {},
+		}
+
+		for search, expect := range tests {
+			r, err := tx.SearchTag(search)
+			if err != nil {
+				t.Fatalf("Failed to search tags %q: %v", search, err)
+			}
+
+			if eq := deep.Equal(r, expect); eq != nil {
+				t.Fatalf("Unexpected tags searching %q: %v", search, eq)
+			}
+		}
+	})
+}
+
+func TestSearchTagHiddenCandidates(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	s := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		testNewTaggedPost(t, tx, "public_tag")
+
+		// More hidden tags than the candidates, all used more than the
+		// visible one.
+		var hidden = make([]string, searchTagCandidates+1)
+		for i := range hidden {
+			hidden[i] = fmt.Sprintf("private_%03d", i)
+		}
+
+		for i := 0; i < 2; i++ {
+			id := testNewTaggedPost(t, tx, hidden...)
+
+			if err := tx.SetPostPermission(id, smolboard.PermissionAdministrator); err != nil {
+				t.Fatal("Failed to hide post:", err)
+			}
+		}
+	})
+
+	tx := testBeginTx(t, d, s.AuthToken)
+
+	r, err := tx.SearchTag("p")
+	if err != nil {
+		t.Fatal("Failed to search tags:", err)
+	}
+
+	expect := []smolboard.PostTag{{TagName: "public_tag", Count: 1}}
+
+	if eq := deep.Equal(r, expect); eq != nil {
+		t.Fatal("Unexpected tags searched:", eq)
+	}
+}
+
+func TestPostSearch(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	p := NewEmptyPost("image/png")
+	p.Size = 1
+
+	if err := tx.SavePost(&p); err != nil {
+		t.Fatal("Failed to save post:", err)
+	}
+
+	// Insert some tags.
+	var tags = _testPostTags
+
+	// Add
+	for _, tag := range tags {
+		if err := tx.TagPost(p.ID, tag); err != nil {
+			t.Fatalf("Failed to tag %q post: %v", tag, err)
+		}
+	}
+
+	sliceEq := func(t *testing.T, s smolboard.SearchResults) {
+		t.Helper()
+
+		if s.Total != 1 {
+			t.Fatal("Invalid total:", s.Total)
+		}
+
+		if s.Sizes != 1 {
+			t.Fatal("Invaid size:", s.Sizes)
+		}
+
+		if len(s.Posts) != 1 {
+			t.Fatal("Invalid posts found with valid search query:", s)
+		}
+
+		if eq := deep.Equal(p, s.Posts[0]); eq != nil {
+			t.Fatal("Returned post is different:", eq)
+		}
+	}
+
+	t.Run("Author", func(t *testing.T) {
+		s, err := tx.PostSearch("@ひめありかわ", smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		sliceEq(t, s)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		s, err := tx.PostSearch(`"otoko no ko" blush skirt`, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
 
 		sliceEq(t, s)
 	})
 
 	t.Run("AuthorAndTags", func(t *testing.T) {
-		s, err := tx.PostSearch(`"otoko no ko" blush @ひめありかわ skirt`, 25, 0)
+		s, err := tx.PostSearch(`"otoko no ko" blush @ひめありかわ skirt`, smolboard.Cursor{}, 25, 0)
 		if err != nil {
 			t.Fatal("Failed to search:", err)
 		}
@@ -560,8 +1148,28 @@ func TestPostSearch(t *testing.T) {
 		sliceEq(t, s)
 	})
 
+	t.Run("Exclude", func(t *testing.T) {
+		s, err := tx.PostSearch(`blush -nonexistent -'also nonexistent'`, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		sliceEq(t, s)
+	})
+
+	t.Run("ExcludeMatch", func(t *testing.T) {
+		s, err := tx.PostSearch(`blush -"otoko no ko"`, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		if len(s.Posts) > 0 || s.Total > 0 {
+			t.Fatal("Unexpected posts found with excluded tag:", s)
+		}
+	})
+
 	t.Run("Empty", func(t *testing.T) {
-		s, err := tx.PostSearch("", 25, 0)
+		s, err := tx.PostSearch("", smolboard.Cursor{}, 25, 0)
 		if err != nil {
 			t.Fatal("Failed to search:", err)
 		}
@@ -569,3 +1177,421 @@ func TestPostSearch(t *testing.T) {
 		sliceEq(t, s)
 	})
 }
+
+func TestPostSearchExpr(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	var postTags = [][]string{
+		{"cat", "outdoor"},
+		{"dog", "outdoor"},
+		{"cat", "indoor"},
+		{"bird", "outdoor"},
+	}
+
+	var posts = make([]smolboard.Post, len(postTags))
+
+	for i, tags := range postTags {
+		p := NewEmptyPost("image/png")
+		// Use powers of 2 so the sizes tell which posts are summed.
+		p.Size = 1 << i
+
+		if err := tx.SavePost(&p); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+
+		for _, tag := range tags {
+			if err := tx.TagPost(p.ID, tag); err != nil {
+				t.Fatalf("Failed to tag %q post: %v", tag, err)
+			}
+		}
+
+		posts[i] = p
+	}
+
+	var tests = []struct {
+		query string
+		posts []int
+	}{
+		{"(cat OR dog) outdoor", []int{1, 0}},
+		{"cat OR dog", []int{2, 1, 0}},
+		{"outdoor -(cat OR dog)", []int{3}},
+		{"NOT cat", []int{3, 1}},
+		{"cat OR -outdoor", []int{2, 0}},
+		{"@ひめありかわ (bird OR indoor)", []int{3, 2}},
+		{"-@ひめありかわ", nil},
+	}
+
+	for _, test := range tests {
+		s, err := tx.PostSearch(test.query, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatalf("Failed to search %q: %v", test.query, err)
+		}
+
+		var expect = make([]smolboard.Post, len(test.posts))
+		var sizes int64
+
+		for i, ix := range test.posts {
+			expect[i] = posts[ix]
+			sizes += posts[ix].Size
+		}
+
+		if eq := deep.Equal(s.Posts, expect); eq != nil {
+			t.Fatalf("Unexpected posts searching %q: %v", test.query, eq)
+		}
+
+		if s.Total != len(expect) {
+			t.Fatalf("Unexpected total searching %q: %d", test.query, s.Total)
+		}
+
+		if s.Sizes != sizes {
+			t.Fatalf("Unexpected sizes searching %q: %d", test.query, s.Sizes)
+		}
+	}
+}
+
+func TestPostSearchMetadata(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	var posts = []smolboard.Post{
+		NewEmptyPost("image/png"),
+		NewEmptyPost("video/mp4"),
+		NewEmptyPost("image/jpeg"),
+		NewEmptyPost("image/gif"),
+	}
+
+	posts[0].Size = 1 << 20
+	posts[0].Attributes = smolboard.PostAttribute{Width: 1920, Height: 1080}
+	posts[1].Size = 20 << 20
+	posts[1].Attributes = smolboard.PostAttribute{Width: 1280, Height: 720}
+	posts[2].Size = 100
+	posts[2].Attributes = smolboard.PostAttribute{Width: 1000, Height: 1000}
+	posts[3].Size = 5
+	// Backdate the last post.
+	posts[3].ID = NewZeroID(time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	for i := range posts {
+		if err := tx.SavePost(&posts[i]); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+	}
+
+	var tests = []struct {
+		query string
+		posts []int
+	}{
+		{"type:video", []int{1}},
+		{"type:image -mime:image/png", []int{2, 3}},
+		{"size:>10MB", []int{1}},
+		{"size:<=100", []int{2, 3}},
+		{"width:>=1280", []int{1, 0}},
+		{"height:1000", []int{2}},
+		{"ratio:16:9", []int{1, 0}},
+		{"ratio:<1.5", []int{2}},
+		{"before:2020-01-01", []int{3}},
+		{"after:2020-01-01", []int{2, 1, 0}},
+		{"after:2019-01-01 before:2019-12-31T00:00:00Z", []int{3}},
+	}
+
+	for _, test := range tests {
+		s, err := tx.PostSearch(test.query, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatalf("Failed to search %q: %v", test.query, err)
+		}
+
+		var expect = make([]smolboard.Post, len(test.posts))
+		for i, ix := range test.posts {
+			expect[i] = posts[ix]
+		}
+
+		if eq := deep.Equal(s.Posts, expect); eq != nil {
+			t.Fatalf("Unexpected posts searching %q: %v", test.query, eq)
+		}
+
+		if s.Total != len(expect) {
+			t.Fatalf("Unexpected total searching %q: %d", test.query, s.Total)
+		}
+	}
+}
+
+func TestPostSearchOrder(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	// Sizes and tag counts are deliberately not in the same order as the IDs.
+	var postSizes = []int64{300, 100, 400, 200}
+	var postTags = []int{1, 3, 0, 2}
+
+	var posts = make([]smolboard.Post, len(postSizes))
+
+	for i, size := range postSizes {
+		p := NewEmptyPost("image/png")
+		p.Size = size
+
+		if err := tx.SavePost(&p); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+
+		for j := 0; j < postTags[i]; j++ {
+			if err := tx.TagPost(p.ID, fmt.Sprintf("tag%d", j)); err != nil {
+				t.Fatal("Failed to tag post:", err)
+			}
+		}
+
+		posts[i] = p
+	}
+
+	var tests = []struct {
+		query string
+		posts []int
+	}{
+		{"", []int{3, 2, 1, 0}},
+		{"order:newest", []int{3, 2, 1, 0}},
+		{"order:oldest", []int{0, 1, 2, 3}},
+		{"order:largest", []int{2, 0, 3, 1}},
+		{"order:smallest", []int{1, 3, 0, 2}},
+		{"order:most-tagged", []int{1, 3, 0, 2}},
+		{"tag0 order:smallest", []int{1, 3, 0}},
+	}
+
+	for _, test := range tests {
+		s, err := tx.PostSearch(test.query, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatalf("Failed to search %q: %v", test.query, err)
+		}
+
+		var expect = make([]smolboard.Post, len(test.posts))
+		for i, ix := range test.posts {
+			expect[i] = posts[ix]
+		}
+
+		if eq := deep.Equal(s.Posts, expect); eq != nil {
+			t.Fatalf("Unexpected posts searching %q: %v", test.query, eq)
+		}
+	}
+
+	t.Run("Random", func(t *testing.T) {
+		r1, err := tx.PostSearch("order:random:42", smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		if len(r1.Posts) != len(posts) {
+			t.Fatal("Unexpected posts length:", len(r1.Posts))
+		}
+
+		// Paginating with the same seed should give the same order.
+		for i, expect := range r1.Posts {
+			r, err := tx.PostSearch("order:random:42", smolboard.Cursor{}, 1, uint(i))
+			if err != nil {
+				t.Fatal("Failed to search page:", err)
+			}
+
+			if len(r.Posts) != 1 || r.Posts[0].ID != expect.ID {
+				t.Fatalf("Unexpected post in page %d: %v", i, r.Posts)
+			}
+		}
+	})
+
+	t.Run("RandomWithoutSeed", func(t *testing.T) {
+		r1, err := tx.PostSearch("order:random", smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		if r1.Seed == 0 {
+			t.Fatal("No seed picked for random order")
+		}
+
+		// Searching with the picked seed should give the same order.
+		q := fmt.Sprintf("order:random:%d", r1.Seed)
+
+		r2, err := tx.PostSearch(q, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search with seed:", err)
+		}
+
+		if r2.Seed != r1.Seed {
+			t.Fatalf("Unexpected seed %d searching %q", r2.Seed, q)
+		}
+
+		if eq := deep.Equal(r2.Posts, r1.Posts); eq != nil {
+			t.Fatal("Unexpected posts searching with the picked seed:", eq)
+		}
+	})
+
+	t.Run("InvalidOrder", func(t *testing.T) {
+		q := smolboard.Query{Order: smolboard.Order{By: "invalid"}}
+
+		if _, err := tx.PostSearchQuery(q, smolboard.Cursor{}, 25, 0); err != smolboard.ErrInvalidOrder {
+			t.Fatal("Unexpected error with invalid order:", err)
+		}
+	})
+}
+
+func TestPostSearchCursor(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	var posts = make([]smolboard.Post, 7)
+
+	for i := range posts {
+		p := NewEmptyPost("image/png")
+		p.Size = int64(len(posts)-i) * 100
+
+		if err := tx.SavePost(&p); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+
+		posts[i] = p
+	}
+
+	// Both orders list the posts from the oldest to the latest.
+	for _, query := range []string{"order:oldest", "order:largest"} {
+		t.Run(query, func(t *testing.T) {
+			var pages [][]smolboard.Post
+			var cursor smolboard.Cursor
+
+			for {
+				r, err := tx.PostSearch(query, cursor, 3, 0)
+				if err != nil {
+					t.Fatal("Failed to search:", err)
+				}
+
+				if r.Total != len(posts) {
+					t.Fatal("Unexpected total:", r.Total)
+				}
+
+				if len(pages) == 0 && r.PrevCursor != 0 {
+					t.Fatal("Unexpected previous cursor in the first page:", r.PrevCursor)
+				}
+
+				pages = append(pages, r.Posts)
+
+				if r.NextCursor == 0 {
+					break
+				}
+
+				cursor = smolboard.Cursor{After: r.NextCursor}
+			}
+
+			var expect = [][]smolboard.Post{posts[0:3], posts[3:6], posts[6:7]}
+
+			if eq := deep.Equal(pages, expect); eq != nil {
+				t.Fatal("Unexpected pages paginating forwards:", eq)
+			}
+
+			// Go back from the last page.
+			r, err := tx.PostSearch(query, smolboard.Cursor{Before: posts[6].ID}, 3, 0)
+			if err != nil {
+				t.Fatal("Failed to search backwards:", err)
+			}
+
+			if eq := deep.Equal(r.Posts, posts[3:6]); eq != nil {
+				t.Fatal("Unexpected posts paginating backwards:", eq)
+			}
+
+			if r.PrevCursor != posts[3].ID || r.NextCursor != posts[5].ID {
+				t.Fatalf("Unexpected cursors: %d, %d", r.PrevCursor, r.NextCursor)
+			}
+
+			// The first page has no previous page.
+			r, err = tx.PostSearch(query, smolboard.Cursor{Before: posts[3].ID}, 3, 0)
+			if err != nil {
+				t.Fatal("Failed to search backwards:", err)
+			}
+
+			if eq := deep.Equal(r.Posts, posts[0:3]); eq != nil {
+				t.Fatal("Unexpected posts in the first page:", eq)
+			}
+
+			if r.PrevCursor != 0 {
+				t.Fatal("Unexpected previous cursor in the first page:", r.PrevCursor)
+			}
+		})
+	}
+
+	t.Run("Offset", func(t *testing.T) {
+		r, err := tx.PostSearch("order:oldest", smolboard.Cursor{After: posts[0].ID}, 2, 1)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		if eq := deep.Equal(r.Posts, posts[3:5]); eq != nil {
+			t.Fatal("Unexpected posts with offset:", eq)
+		}
+	})
+
+	t.Run("UploadInBetween", func(t *testing.T) {
+		r, err := tx.PostSearch("", smolboard.Cursor{}, 3, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		p := NewEmptyPost("image/png")
+		p.Size = 1
+
+		if err := tx.SavePost(&p); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+
+		// The next page should not shift because of the new post.
+		r, err = tx.PostSearch("", smolboard.Cursor{After: r.NextCursor}, 3, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		if eq := deep.Equal(r.Posts, []smolboard.Post{posts[3], posts[2], posts[1]}); eq != nil {
+			t.Fatal("Unexpected posts after uploading:", eq)
+		}
+	})
+
+	t.Run("BothCursors", func(t *testing.T) {
+		c := smolboard.Cursor{Before: posts[0].ID, After: posts[1].ID}
+
+		if _, err := tx.PostSearch("", c, 3, 0); err != smolboard.ErrCursorHasBoth {
+			t.Fatal("Unexpected error with both cursors:", err)
+		}
+	})
+
+	t.Run("DeletedCursor", func(t *testing.T) {
+		r, err := tx.PostSearch("order:largest", smolboard.Cursor{}, 3, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		if err := tx.DeletePost(r.NextCursor); err != nil {
+			t.Fatal("Failed to delete cursor post:", err)
+		}
+
+		// Orders by a key can't tell where the deleted post was.
+		c := smolboard.Cursor{After: r.NextCursor}
+
+		if _, err := tx.PostSearch("order:largest", c, 3, 0); err != smolboard.ErrPostNotFound {
+			t.Fatal("Unexpected error paging after deleted post:", err)
+		}
+
+		// Orders by ID still can.
+		r, err = tx.PostSearch("order:oldest", c, 3, 0)
+		if err != nil {
+			t.Fatal("Failed to search after deleted post by ID:", err)
+		}
+
+		if eq := deep.Equal(r.Posts, posts[3:6]); eq != nil {
+			t.Fatal("Unexpected posts after deleted post:", eq)
+		}
+	})
+}
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/smolboard/smolboard"
+)
+
+// attribute returns the SQL expression to extract the given key from the posts'
+// JSON attributes. The column is stored as a BLOB, so it has to be casted for
+// the JSON functions to accept it.
+func attribute(key string) string {
+	return fmt.Sprintf("json_extract(CAST(posts.attributes AS TEXT), '$.%s')", key)
+}
+
+// queryFields maps comparable fields to their SQL expressions.
+var queryFields = map[smolboard.QueryField]string{
+	smolboard.QueryFieldSize:   "posts.size",
+	smolboard.QueryFieldWidth:  attribute("w"),
+	smolboard.QueryFieldHeight: attribute("h"),
+	// Dividing by a zero or missing height gives NULL, which never matches.
+	smolboard.QueryFieldRatio: fmt.Sprintf(
+		"(CAST(%s AS REAL) / %s)", attribute("w"), attribute("h"),
+	),
+	smolboard.QueryFieldScore: "posts.score",
+}
+
+// queryOps maps query operators to SQL operators. They're the same for now,
+// but this guards against unknown operators being written into the query.
+var queryOps = map[smolboard.QueryOp]string{
+	smolboard.OpEqual:        "=",
+	smolboard.OpLess:         "<",
+	smolboard.OpLessEqual:    "<=",
+	smolboard.OpGreater:      ">",
+	smolboard.OpGreaterEqual: ">=",
+}
+
+// ratioTolerance is the maximum difference for two ratios to be equal. It
+// allows 16:9 to match 1366x768.
+const ratioTolerance = 0.01
+
+// queryBuilder builds an SQL condition for the posts table from a parsed
+// search query.
+type queryBuilder struct {
+	strings.Builder
+	args []interface{}
+
+	// username and permission are of the user searching, which some terms
+	// need to check the visibility of other things.
+	username   string
+	permission smolboard.Permission
+}
+
+// expr writes the given expression as a condition into the builder.
+func (b *queryBuilder) expr(expr smolboard.QueryExpr) error {
+	switch expr := expr.(type) {
+	case smolboard.QueryAnd:
+		return b.join(" AND ", expr)
+
+	case smolboard.QueryOr:
+		return b.join(" OR ", expr)
+
+	case smolboard.QueryNot:
+		b.WriteString("NOT (")
+		if err := b.expr(expr.Expr); err != nil {
+			return err
+		}
+		b.WriteString(")")
+
+	case smolboard.QueryTag:
+		// Search for the canonical tag if the tag is an alias.
+		b.WriteString(`EXISTS (
+			SELECT 1 FROM posttags
+			WHERE posttags.postid = posts.id AND posttags.tagname = COALESCE(
+				(SELECT tagname FROM tagaliases WHERE alias = ?), ?))`)
+		b.args = append(b.args, string(expr), string(expr))
+
+	case smolboard.QueryNamespace:
+		// The namespace is validated to not have any glob characters.
+		b.WriteString(`EXISTS (
+			SELECT 1 FROM posttags
+			WHERE posttags.postid = posts.id AND posttags.tagname GLOB ? || ':*')`)
+		b.args = append(b.args, string(expr))
+
+	case smolboard.QueryPoster:
+		// Use IS instead of = so that negating this will also match posts from
+		// deleted users, which have a NULL poster.
+		b.WriteString("posts.poster IS ?")
+		b.args = append(b.args, string(expr))
+
+	case smolboard.QueryType:
+		// The type is validated to not have any glob characters.
+		b.WriteString("posts.contenttype GLOB ? || '/*'")
+		b.args = append(b.args, string(expr))
+
+	case smolboard.QueryMIME:
+		b.WriteString("posts.contenttype = ?")
+		b.args = append(b.args, string(expr))
+
+	case smolboard.QueryText:
+		b.WriteString("posts.id IN (SELECT rowid FROM postsearch WHERE postsearch MATCH ?)")
+		b.args = append(b.args, matchPhrases(string(expr)))
+
+	case smolboard.QueryFavorite:
+		b.WriteString(`EXISTS (
+			SELECT 1 FROM favorites
+			WHERE favorites.postid = posts.id AND favorites.username = ?)`)
+		b.args = append(b.args, string(expr))
+
+	case smolboard.QueryPool:
+		// Don't reveal the posts in pools that the user cannot see.
+		b.WriteString(`EXISTS (
+			SELECT 1 FROM poolposts
+			JOIN   pools ON pools.id = poolposts.poolid
+			WHERE  poolposts.postid = posts.id AND poolposts.poolid = ? AND ` + sqlPoolVisible + `)`)
+		b.args = append(b.args, int64(expr), b.username, b.permission)
+
+	case smolboard.QueryHash:
+		b.WriteString("posts.hash = ?")
+		b.args = append(b.args, string(expr))
+
+	case smolboard.QueryParent:
+		b.WriteString("posts.parent = ?")
+		b.args = append(b.args, int64(expr))
+
+	case smolboard.QueryCompare:
+		column, ok := queryFields[expr.Field]
+		if !ok {
+			return fmt.Errorf("unknown query field %q", expr.Field)
+		}
+
+		// Ratios are floats, so an exact comparison would almost never match.
+		if expr.Field == smolboard.QueryFieldRatio && expr.Op == smolboard.OpEqual {
+			fmt.Fprintf(b, "ABS(%s - ?) < %g", column, ratioTolerance)
+			b.args = append(b.args, expr.Value)
+			break
+		}
+
+		op, ok := queryOps[expr.Op]
+		if !ok {
+			return fmt.Errorf("unknown query operator %q", expr.Op)
+		}
+
+		fmt.Fprintf(b, "%s %s ?", column, op)
+		b.args = append(b.args, expr.Value)
+
+	case smolboard.QueryDate:
+		// Post IDs are Snowflakes, so the creation time can be compared using
+		// the ID of the earliest possible Snowflake at that time.
+		if expr.Before {
+			b.WriteString("posts.id < ?")
+		} else {
+			b.WriteString("posts.id >= ?")
+		}
+		b.args = append(b.args, NewZeroID(expr.Time))
+
+	default:
+		return fmt.Errorf("unknown query expression %T", expr)
+	}
+
+	return nil
+}
+
+// matchPhrases returns the FTS5 query matching all words in the text. Each
+// word is quoted, so none of it is read as FTS5 syntax.
+func matchPhrases(text string) string {
+	var words = strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+func (b *queryBuilder) join(sep string, exprs []smolboard.QueryExpr) error {
+	b.WriteByte('(')
+
+	for i, expr := range exprs {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+
+		if err := b.expr(expr); err != nil {
+			return err
+		}
+	}
+
+	b.WriteByte(')')
+	return nil
+}
+
+// queryTags returns all tags in the query expression, including negated ones.
+func queryTags(expr smolboard.QueryExpr) []string {
+	switch expr := expr.(type) {
+	case smolboard.QueryAnd:
+		return queryTagsJoin(expr)
+	case smolboard.QueryOr:
+		return queryTagsJoin(expr)
+	case smolboard.QueryNot:
+		return queryTags(expr.Expr)
+	case smolboard.QueryTag:
+		return []string{string(expr)}
+	default:
+		return nil
+	}
+}
+
+func queryTagsJoin(exprs []smolboard.QueryExpr) []string {
+	var tags []string
+	for _, expr := range exprs {
+		tags = append(tags, queryTags(expr)...)
+	}
+	return tags
+}
+
+// postOrder describes how searched posts are sorted.
+type postOrder struct {
+	// key returns the SQL expression to sort posts by from the given posts
+	// table. Posts with the same key are sorted by their IDs in the same
+	// direction. It is nil if the posts are only sorted by their IDs.
+	key  func(table string) string
+	desc bool
+}
+
+// randomPrime is the modulo used to shuffle posts. It is a Mersenne prime
+// small enough that multiplying two numbers under it never overflows.
+const randomPrime = 1<<31 - 1
+
+func newPostOrder(order smolboard.Order) (postOrder, error) {
+	switch order.By {
+	case "", smolboard.OrderNewest:
+		return postOrder{desc: true}, nil
+
+	case smolboard.OrderOldest:
+		return postOrder{desc: false}, nil
+
+	case smolboard.OrderLargest, smolboard.OrderSmallest:
+		return postOrder{
+			key:  func(table string) string { return table + ".size" },
+			desc: order.By == smolboard.OrderLargest,
+		}, nil
+
+	case smolboard.OrderMostTagged:
+		return postOrder{
+			key: func(table string) string {
+				return fmt.Sprintf(
+					"(SELECT COUNT(1) FROM posttags WHERE posttags.postid = %s.id)", table,
+				)
+			},
+			desc: true,
+		}, nil
+
+	case smolboard.OrderScore:
+		return postOrder{
+			key:  func(table string) string { return table + ".score" },
+			desc: true,
+		}, nil
+
+	case smolboard.OrderRandom:
+		// The seed is picked by posts if there's none. Map the seed to a
+		// non-zero multiplier, since multiplying by zero
+		// would give the same key for every post.
+		var seed = order.Seed % (randomPrime - 1)
+		if seed < 0 {
+			seed = -seed
+		}
+		seed++
+
+		return postOrder{
+			// This is a multiplicative hash of the ID, which is stable for the
+			// same seed.
+			key: func(table string) string {
+				return fmt.Sprintf("((%s.id %% %d) * %d) %% %d", table, randomPrime, seed, randomPrime)
+			},
+		}, nil
+
+	default:
+		return postOrder{}, smolboard.ErrInvalidOrder
+	}
+}
+
+// reverse returns the order in the opposite direction.
+func (o postOrder) reverse() postOrder {
+	o.desc = !o.desc
+	return o
+}
+
+// after returns the condition for posts listed after the cursor post, whose ID
+// is the argument. The cursor post must exist for the posts to be listed,
+// unless they're only sorted by their IDs, which posts checks beforehand.
+func (o postOrder) after() string {
+	var op = ">"
+	if o.desc {
+		op = "<"
+	}
+
+	if o.key == nil {
+		return fmt.Sprintf("posts.id %s ?", op)
+	}
+
+	return fmt.Sprintf(
+		"(%s, posts.id) %s (SELECT %s, cursor.id FROM posts AS cursor WHERE cursor.id = ?)",
+		o.key("posts"), op, o.key("cursor"),
+	)
+}
+
+// clause returns the ORDER BY clause for the posts table.
+func (o postOrder) clause() string {
+	var dir = "ASC"
+	if o.desc {
+		dir = "DESC"
+	}
+
+	if o.key == nil {
+		return fmt.Sprintf("ORDER BY posts.id %s", dir)
+	}
+
+	return fmt.Sprintf("ORDER BY %s %s, posts.id %s", o.key("posts"), dir, dir)
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+// SimilarPosts returns up to count visible posts whose perceptual hashes are
+// within maxDistance of the given hash, most similar first. Posts that are
+// equally similar are sorted newest first.
+func (d *Transaction) SimilarPosts(
+	phash int64, maxDistance int, count uint) ([]smolboard.SimilarPost, error) {
+
+	if count > 100 {
+		return nil, smolboard.ErrPageCountLimit
+	}
+
+	if maxDistance < 0 || maxDistance > 64 {
+		return nil, smolboard.ErrInvalidDistance
+	}
+
+	p, err := d.Permission()
+	if err != nil {
+		return nil, err
+	}
+
+	where, err := d.searchWhere(smolboard.AllPosts, p)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite can't count bits, so the distances are computed here. Only the
+	// hashes are scanned, since every post with one has to be checked.
+	q, err := d.Queryx(
+		"SELECT posts.id, posts.phash "+where.String()+" AND posts.phash IS NOT NULL",
+		where.args...,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query hashes")
+	}
+
+	defer q.Close()
+
+	var similar []smolboard.SimilarPost
+
+	for q.Next() {
+		var post smolboard.SimilarPost
+		var hash int64
+
+		if err := q.Scan(&post.ID, &hash); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan hash")
+		}
+
+		post.Distance = bits.OnesCount64(uint64(phash ^ hash))
+		if post.Distance <= maxDistance {
+			similar = append(similar, post)
+		}
+	}
+
+	if err := q.Err(); err != nil {
+		return nil, errors.Wrap(err, "Failed to iterate hashes")
+	}
+
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Distance != similar[j].Distance {
+			return similar[i].Distance < similar[j].Distance
+		}
+		return similar[i].ID > similar[j].ID
+	})
+
+	if uint(len(similar)) > count {
+		similar = similar[:count]
+	}
+
+	if len(similar) == 0 {
+		return []smolboard.SimilarPost{}, nil
+	}
+
+	// Fill in the rest of the posts' fields.
+	var index = make(map[int64]int, len(similar))
+	var args = make([]interface{}, len(similar))
+
+	for i, post := range similar {
+		index[post.ID] = i
+		args[i] = post.ID
+	}
+
+	r, err := d.Queryx(
+		"SELECT * FROM posts WHERE id IN (?"+strings.Repeat(", ?", len(args)-1)+")",
+		args...,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query similar posts")
+	}
+
+	defer r.Close()
+
+	for r.Next() {
+		var post smolboard.Post
+
+		if err := r.StructScan(&post); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan similar post")
+		}
+
+		similar[index[post.ID]].Post = post
+	}
+
+	return similar, r.Err()
+}
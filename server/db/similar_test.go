@@ -0,0 +1,86 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-test/deep"
+)
+
+func TestSimilarPosts(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	var same, near, far, hidden int64
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		var save = func(phash *int64, perm smolboard.Permission) int64 {
+			p := NewEmptyPost("image/png")
+			p.Size = 1
+			p.PHash = phash
+			p.Permission = perm
+
+			if err := tx.SavePost(&p); err != nil {
+				t.Fatal("Failed to save post:", err)
+			}
+
+			return p.ID
+		}
+
+		var hashes = []int64{0, 0x7, -1, 0x1}
+
+		same = save(&hashes[0], smolboard.PermissionGuest)
+		near = save(&hashes[1], smolboard.PermissionGuest)
+		far = save(&hashes[2], smolboard.PermissionGuest)
+		hidden = save(&hashes[3], smolboard.PermissionAdministrator)
+		// Posts without hashes are never similar.
+		save(nil, smolboard.PermissionGuest)
+	})
+
+	var test = func(t *testing.T, token string, maxDistance int, count uint, expect []int64) {
+		t.Helper()
+
+		s, err := testBeginTx(t, d, token).SimilarPosts(0, maxDistance, count)
+		if err != nil {
+			t.Fatal("Failed to get similar posts:", err)
+		}
+
+		var ids = make([]int64, len(s))
+		for i, p := range s {
+			ids[i] = p.ID
+
+			// Check that the posts are filled in.
+			if p.ContentType == "" {
+				t.Fatalf("Post %d has no content type", p.ID)
+			}
+		}
+
+		if eq := deep.Equal(ids, expect); eq != nil {
+			t.Fatal("Unexpected similar posts:", eq)
+		}
+	}
+
+	t.Run("User", func(t *testing.T) {
+		test(t, user.AuthToken, smolboard.DefaultSimilarDistance, 25, []int64{same, near})
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		test(t, owner.AuthToken, smolboard.DefaultSimilarDistance, 25, []int64{same, hidden, near})
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		test(t, owner.AuthToken, 64, 2, []int64{same, hidden})
+		test(t, owner.AuthToken, 0, 25, []int64{same})
+		test(t, owner.AuthToken, 64, 25, []int64{same, hidden, near, far})
+
+		_, err := testBeginTx(t, d, owner.AuthToken).SimilarPosts(0, 65, 25)
+		if err != smolboard.ErrInvalidDistance {
+			t.Fatal("Unexpected error with invalid distance:", err)
+		}
+	})
+
+}
@@ -9,11 +9,19 @@ import (
 const (
 	postIDNode int64 = iota
 	sessionIDNode
+	tagRevisionIDNode
+	tagEditIDNode
+	commentIDNode
+	poolIDNode
 )
 
 var (
-	postIDGen    = mustSnowflake(postIDNode)
-	sessionIDGen = mustSnowflake(sessionIDNode)
+	postIDGen        = mustSnowflake(postIDNode)
+	sessionIDGen     = mustSnowflake(sessionIDNode)
+	tagRevisionIDGen = mustSnowflake(tagRevisionIDNode)
+	tagEditIDGen     = mustSnowflake(tagEditIDNode)
+	commentIDGen     = mustSnowflake(commentIDNode)
+	poolIDGen        = mustSnowflake(poolIDNode)
 )
 
 func mustSnowflake(node int64) *snowflake.Node {
@@ -25,6 +33,9 @@ func mustSnowflake(node int64) *snowflake.Node {
 	return n
 }
 
+// snowflakeEpoch is the earliest time of a Snowflake.
+var snowflakeEpoch = time.Unix(0, snowflake.Epoch*int64(time.Millisecond))
+
 func NewZeroID(t time.Time) int64 {
 	epoch := t.UnixNano() / int64(time.Millisecond)
 	epoch -= snowflake.Epoch
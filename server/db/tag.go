@@ -0,0 +1,1095 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+// RebuildTags initializes the database then recounts all tags from the posts.
+// This fixes the tag counts if they're inconsistent with the posts, such as
+// after manually editing the database.
+func RebuildTags(config DBConfig) error {
+	d, err := NewDatabase(config)
+	if err != nil {
+		return errors.Wrap(err, "Failed to initialize database")
+	}
+	defer d.Close()
+
+	return d.AcquireGuest(context.Background(), func(tx *Transaction) error {
+		return tx.rebuildTags()
+	})
+}
+
+// RebuildTags recounts all tags from the posts. Only the owner can do this.
+func (d *Transaction) RebuildTags() error {
+	if err := d.HasPermission(smolboard.PermissionOwner, true); err != nil {
+		return err
+	}
+
+	return d.rebuildTags()
+}
+
+func (d *Transaction) rebuildTags() error {
+	_, err := d.Exec(`
+		UPDATE tags SET count = (
+			SELECT COUNT(1) FROM posttags WHERE posttags.tagname = tags.name
+		)`,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to recount tags")
+	}
+
+	_, err = d.Exec(`
+		INSERT INTO tags (name, count, created)
+		SELECT tagname, COUNT(1), ` + sqlUnixNano + ` FROM posttags
+		WHERE  tagname NOT IN (SELECT name FROM tags)
+		GROUP  BY tagname`,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to insert missing tags")
+	}
+
+	return nil
+}
+
+// canonicalTag returns the canonical tag of the given tag. The tag itself is
+// returned if it's not an alias.
+func (d *Transaction) canonicalTag(tag string) (string, error) {
+	var canonical string
+
+	err := d.QueryRow("SELECT tagname FROM tagaliases WHERE alias = ?", tag).Scan(&canonical)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return tag, nil
+		}
+		return "", errors.Wrap(err, "Failed to get tag alias")
+	}
+
+	return canonical, nil
+}
+
+// TagAliases returns the aliases of the given tag. If the given tag is an
+// alias, then the aliases of its canonical tag are returned.
+func (d *Transaction) TagAliases(tag string) ([]smolboard.TagAlias, error) {
+	if err := validTagName(tag); err != nil {
+		return nil, err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := d.Queryx("SELECT * FROM tagaliases WHERE tagname = ? ORDER BY alias ASC", tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query tag aliases")
+	}
+
+	defer r.Close()
+
+	var aliases = []smolboard.TagAlias{}
+
+	for r.Next() {
+		var alias smolboard.TagAlias
+
+		if err := r.StructScan(&alias); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan tag alias")
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, nil
+}
+
+// AddTagAlias makes the alias an alias of the given tag. Posts already tagged
+// with the alias are retagged with the canonical tag, and the implications,
+// restriction and description of the alias are moved to it like RenameTag.
+// Only administrators can do this.
+func (d *Transaction) AddTagAlias(tag, alias string) error {
+	if err := validTag(tag); err != nil {
+		return err
+	}
+	if err := validTagName(alias); err != nil {
+		return err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	// Point the alias to the canonical tag so aliases never chain.
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	if tag == alias {
+		return smolboard.ErrTagAliasSelf
+	}
+
+	var aliases int
+
+	err = d.QueryRow("SELECT COUNT(1) FROM tagaliases WHERE tagname = ?", alias).Scan(&aliases)
+	if err != nil {
+		return errors.Wrap(err, "Failed to count aliases")
+	}
+
+	if aliases > 0 {
+		return smolboard.ErrTagAliasChained
+	}
+
+	if _, err := d.Exec("INSERT INTO tagaliases VALUES (?, ?)", alias, tag); err != nil {
+		if errIsConstraint(err) {
+			return smolboard.ErrTagAliasExists
+		}
+		return errors.Wrap(err, "Failed to insert tag alias")
+	}
+
+	if err := d.retag(alias, tag); err != nil {
+		return err
+	}
+
+	return d.moveTagRelations(alias, tag)
+}
+
+// retag moves the posts and blacklists with the from tag to the to tag. Posts
+// that already have the to tag in any case only have the from tag removed, since
+// retagging them would violate the unique constraint. Each change to the posts
+// is logged like TagPost and UntagPost, and both tags must be ones the user can
+// apply.
+func (d *Transaction) retag(from, to string) error {
+	for _, tag := range []string{from, to} {
+		if err := d.canApplyTag(tag); err != nil {
+			return err
+		}
+	}
+
+	r, err := d.Query("SELECT postid FROM posttags WHERE tagname = ?", from)
+	if err != nil {
+		return errors.Wrap(err, "Failed to query tagged posts")
+	}
+
+	defer r.Close()
+
+	var postIDs []int64
+
+	for r.Next() {
+		var id int64
+
+		if err := r.Scan(&id); err != nil {
+			return errors.Wrap(err, "Failed to scan tagged post")
+		}
+
+		postIDs = append(postIDs, id)
+	}
+
+	// Close early so the posts can be retagged.
+	r.Close()
+
+	for _, id := range postIDs {
+		// Remove first, since the tags may only differ in case.
+		if err := d.deletePostTag(id, from); err != nil {
+			return errors.Wrap(err, "Failed to untag post")
+		}
+
+		err := d.insertPostTag(id, to)
+		if err != nil && !errors.Is(err, smolboard.ErrTagAlreadyAdded) {
+			return errors.Wrap(err, "Failed to retag post")
+		}
+	}
+
+	// Keep blacklists hiding the same posts. Users that already blacklisted
+	// the to tag have the duplicate dropped.
+	_, err = d.Exec("UPDATE OR REPLACE blacklists SET tagname = ? WHERE tagname = ?", to, from)
+	if err != nil {
+		return errors.Wrap(err, "Failed to move blacklisted tags")
+	}
+
+	return nil
+}
+
+// DeleteTagAlias deletes the alias of the given tag. Posts retagged when the
+// alias was added keep the canonical tag. Only administrators can do this.
+func (d *Transaction) DeleteTagAlias(tag, alias string) error {
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	r, err := d.Exec("DELETE FROM tagaliases WHERE alias = ? AND tagname = ?", alias, tag)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete tag alias")
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get rows affected")
+	}
+
+	if count == 0 {
+		return smolboard.ErrTagAliasNotFound
+	}
+
+	return nil
+}
+
+// sqlImpliedTags is the recursive common table expression of all tags
+// transitively implied by the tag in the argument. The graph never has cycles,
+// but UNION would stop them anyway.
+const sqlImpliedTags = `
+	implied(name) AS (
+		SELECT implied FROM tagimplications WHERE tagname = ?
+		UNION
+		SELECT tagimplications.implied FROM tagimplications
+		JOIN   implied ON tagimplications.tagname = implied.name
+	)`
+
+// tagImplied tags the post with all tags implied by the given tag. Tags that
+// the post already has or that the user can't apply are skipped. Each added tag
+// is logged like TagPost.
+func (d *Transaction) tagImplied(postID int64, tag string) error {
+	r, err := d.Query("WITH RECURSIVE"+sqlImpliedTags+" SELECT name FROM implied", tag)
+	if err != nil {
+		return errors.Wrap(err, "Failed to query implied tags")
+	}
+
+	defer r.Close()
+
+	var implied []string
+
+	for r.Next() {
+		var name string
+
+		if err := r.Scan(&name); err != nil {
+			return errors.Wrap(err, "Failed to scan implied tag")
+		}
+
+		implied = append(implied, name)
+	}
+
+	// Close early so the tags can be inserted.
+	r.Close()
+
+	for _, name := range implied {
+		// Skip the restricted tags that the user can't apply.
+		if err := d.canApplyTag(name); err != nil {
+			if errors.Is(err, smolboard.ErrTagRestricted) {
+				continue
+			}
+			return err
+		}
+
+		err := d.insertPostTag(postID, name)
+		if err != nil && !errors.Is(err, smolboard.ErrTagAlreadyAdded) {
+			return errors.Wrap(err, "Failed to tag implied tag")
+		}
+	}
+
+	return nil
+}
+
+// TagImplications returns the tags directly implied by the given tag.
+func (d *Transaction) TagImplications(tag string) ([]smolboard.TagImplication, error) {
+	if err := validTagName(tag); err != nil {
+		return nil, err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := d.Queryx(
+		"SELECT * FROM tagimplications WHERE tagname = ? ORDER BY implied ASC", tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query tag implications")
+	}
+
+	defer r.Close()
+
+	var implications = []smolboard.TagImplication{}
+
+	for r.Next() {
+		var implication smolboard.TagImplication
+
+		if err := r.StructScan(&implication); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan tag implication")
+		}
+
+		implications = append(implications, implication)
+	}
+
+	return implications, nil
+}
+
+// AddTagImplication makes the tag imply the implied tag. Aliases are resolved
+// to their canonical tags. Existing posts are not tagged; use
+// BackfillTagImplications for that. Only administrators can do this.
+func (d *Transaction) AddTagImplication(tag, implied string) error {
+	if err := validTagName(tag); err != nil {
+		return err
+	}
+	if err := validTag(implied); err != nil {
+		return err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	implied, err = d.canonicalTag(implied)
+	if err != nil {
+		return err
+	}
+
+	if tag == implied {
+		return smolboard.ErrTagImplicationCycle
+	}
+
+	// The new implication makes a cycle if the implied tag already implies the
+	// tag.
+	var cycle bool
+
+	err = d.QueryRow(
+		"WITH RECURSIVE"+sqlImpliedTags+`
+		SELECT EXISTS (SELECT 1 FROM implied WHERE name = ?)`,
+		implied, tag,
+	).Scan(&cycle)
+	if err != nil {
+		return errors.Wrap(err, "Failed to check for cycles")
+	}
+
+	if cycle {
+		return smolboard.ErrTagImplicationCycle
+	}
+
+	if _, err := d.Exec("INSERT INTO tagimplications VALUES (?, ?)", tag, implied); err != nil {
+		if errIsConstraint(err) {
+			return smolboard.ErrTagImplicationExists
+		}
+		return errors.Wrap(err, "Failed to insert tag implication")
+	}
+
+	return nil
+}
+
+// DeleteTagImplication deletes the implication from the tag. Posts already
+// tagged with the implied tag keep it. Only administrators can do this.
+func (d *Transaction) DeleteTagImplication(tag, implied string) error {
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	implied, err = d.canonicalTag(implied)
+	if err != nil {
+		return err
+	}
+
+	r, err := d.Exec(
+		"DELETE FROM tagimplications WHERE tagname = ? AND implied = ?", tag, implied)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete tag implication")
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get rows affected")
+	}
+
+	if count == 0 {
+		return smolboard.ErrTagImplicationNotFound
+	}
+
+	return nil
+}
+
+// BackfillTagImplications tags existing posts with the tags implied by the
+// given tag. This includes posts tagged with tags that imply the given tag, so
+// a new implication can be applied by backfilling either of its tags. The
+// number of tags added is returned. Only administrators can do this.
+func (d *Transaction) BackfillTagImplications(tag string) (int64, error) {
+	if err := validTagName(tag); err != nil {
+		return 0, err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return 0, err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	// Sources are the tag and all tags that imply it. Closure then maps each
+	// source to all tags it implies.
+	r, err := d.Query(`
+		WITH RECURSIVE
+		sources(name) AS (
+			SELECT ?
+			UNION
+			SELECT tagimplications.tagname FROM tagimplications
+			JOIN   sources ON tagimplications.implied = sources.name
+		),
+		closure(source, name) AS (
+			SELECT tagname, implied FROM tagimplications
+			WHERE  tagname IN (SELECT name FROM sources)
+			UNION
+			SELECT closure.source, tagimplications.implied FROM tagimplications
+			JOIN   closure ON tagimplications.tagname = closure.name
+		)
+		SELECT DISTINCT posttags.postid, closure.name FROM posttags
+		JOIN   closure ON closure.source = posttags.tagname
+		WHERE  NOT EXISTS (
+			SELECT 1 FROM posttags AS target
+			WHERE  target.postid = posttags.postid
+			  AND  target.tagname = closure.name COLLATE NOCASE
+		)`,
+		tag,
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to query implied tags")
+	}
+
+	defer r.Close()
+
+	var missing []smolboard.PostTag
+
+	for r.Next() {
+		var t smolboard.PostTag
+
+		if err := r.Scan(&t.PostID, &t.TagName); err != nil {
+			return 0, errors.Wrap(err, "Failed to scan implied tag")
+		}
+
+		missing = append(missing, t)
+	}
+
+	// Close early so the tags can be inserted.
+	r.Close()
+
+	var count int64
+	var restricted = map[string]bool{}
+
+	// Each added tag is logged like TagPost. Restricted tags that the user
+	// can't apply are skipped like tagImplied does.
+	for _, t := range missing {
+		skip, ok := restricted[t.TagName]
+		if !ok {
+			err := d.canApplyTag(t.TagName)
+			if err != nil && !errors.Is(err, smolboard.ErrTagRestricted) {
+				return 0, err
+			}
+
+			skip = err != nil
+			restricted[t.TagName] = skip
+		}
+
+		if skip {
+			continue
+		}
+
+		err := d.insertPostTag(t.PostID, t.TagName)
+		if err != nil {
+			if errors.Is(err, smolboard.ErrTagAlreadyAdded) {
+				continue
+			}
+			return 0, errors.Wrap(err, "Failed to backfill implied tag")
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// TagCategories returns all tag categories sorted by their order.
+func (d *Transaction) TagCategories() ([]smolboard.TagCategory, error) {
+	r, err := d.Queryx("SELECT * FROM tagcategories ORDER BY sortorder ASC, name ASC")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query tag categories")
+	}
+
+	defer r.Close()
+
+	var categories = []smolboard.TagCategory{}
+
+	for r.Next() {
+		var category smolboard.TagCategory
+
+		if err := r.StructScan(&category); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan tag category")
+		}
+
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+// SetTagCategory creates the tag category or overrides the existing one with
+// the same name. Only administrators can do this.
+func (d *Transaction) SetTagCategory(c smolboard.TagCategory) error {
+	if err := smolboard.TagCategoryIsValid(c); err != nil {
+		return err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	_, err := d.Exec(`
+		INSERT INTO tagcategories VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			color = excluded.color, sortorder = excluded.sortorder`,
+		c.Name, c.Color, c.SortOrder,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to set tag category")
+	}
+
+	return nil
+}
+
+// DeleteTagCategory deletes the tag category. Tags in the namespace are kept
+// but no longer grouped. Only administrators can do this.
+func (d *Transaction) DeleteTagCategory(name string) error {
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	r, err := d.Exec("DELETE FROM tagcategories WHERE name = ?", name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete tag category")
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get rows affected")
+	}
+
+	if count == 0 {
+		return smolboard.ErrTagCategoryNotFound
+	}
+
+	return nil
+}
+
+// groupTags groups the tags by the categories of their namespaces in the
+// categories' order. Tags without a category are put in the last group.
+// Groups without any tags are omitted.
+func (d *Transaction) groupTags(tags []smolboard.PostTag) ([]smolboard.TagGroup, error) {
+	categories, err := d.TagCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups = make([]smolboard.TagGroup, len(categories)+1)
+	var index = make(map[string]int, len(categories))
+
+	for i, category := range categories {
+		groups[i].Category = category
+		index[category.Name] = i
+	}
+
+	for _, tag := range tags {
+		i, ok := index[tag.Namespace()]
+		if !ok {
+			i = len(categories)
+		}
+
+		groups[i].Tags = append(groups[i].Tags, tag)
+	}
+
+	var nonEmpty = groups[:0]
+
+	for _, group := range groups {
+		if len(group.Tags) > 0 {
+			nonEmpty = append(nonEmpty, group)
+		}
+	}
+
+	return nonEmpty, nil
+}
+
+// RenameTag renames the tag on all posts. The new name must not be used by any
+// post; use MergeTags for that. Aliases and implications of the tag are moved
+// to the new name. Only administrators can do this.
+func (d *Transaction) RenameTag(from, to string) error {
+	if err := validTagName(from); err != nil {
+		return err
+	}
+	if err := validTag(to); err != nil {
+		return err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	to, err := d.canonicalTag(to)
+	if err != nil {
+		return err
+	}
+
+	if from == to {
+		return nil
+	}
+
+	if err := d.tagExists(from); err != nil {
+		return err
+	}
+
+	// Renaming to a different case of the same tag is fine, as each post can
+	// only have one of them.
+	var used bool
+
+	err = d.QueryRow("SELECT EXISTS (SELECT 1 FROM posttags WHERE tagname = ?)", to).Scan(&used)
+	if err != nil {
+		return errors.Wrap(err, "Failed to check new tag")
+	}
+
+	if used {
+		return smolboard.ErrTagExists
+	}
+
+	if err := d.retag(from, to); err != nil {
+		return err
+	}
+
+	return d.moveTagRelations(from, to)
+}
+
+// MergeTags moves the posts tagged with the from tag to the into tag. Posts
+// with both tags are only left with the into tag. Aliases and implications of
+// the from tag are moved to the into tag. Only administrators can do this.
+func (d *Transaction) MergeTags(from, into string) error {
+	if err := validTagName(from); err != nil {
+		return err
+	}
+	if err := validTag(into); err != nil {
+		return err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	into, err := d.canonicalTag(into)
+	if err != nil {
+		return err
+	}
+
+	if from == into {
+		return smolboard.ErrTagMergeSelf
+	}
+
+	if err := d.tagExists(from); err != nil {
+		return err
+	}
+
+	if err := d.retag(from, into); err != nil {
+		return err
+	}
+
+	return d.moveTagRelations(from, into)
+}
+
+// tagExists returns ErrTagNotFound if no posts have the tag.
+func (d *Transaction) tagExists(tag string) error {
+	var count int
+
+	if err := d.QueryRow("SELECT count FROM tags WHERE name = ?", tag).Scan(&count); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrap(err, "Failed to get tag")
+		}
+	}
+
+	if count == 0 {
+		return smolboard.ErrTagNotFound
+	}
+
+	return nil
+}
+
+// moveTagRelations points the aliases, implications, restriction and
+// description of the from tag to the to tag. Implications that the to tag
+// already has or that would make it imply itself are dropped, and the
+// restriction and description are only moved if the to tag has none.
+func (d *Transaction) moveTagRelations(from, to string) error {
+	_, err := d.Exec("UPDATE tagaliases SET tagname = ? WHERE tagname = ?", to, from)
+	if err != nil {
+		return errors.Wrap(err, "Failed to move tag aliases")
+	}
+
+	_, err = d.Exec("UPDATE OR IGNORE tagimplications SET tagname = ? WHERE tagname = ?", to, from)
+	if err != nil {
+		return errors.Wrap(err, "Failed to move tag implications")
+	}
+
+	_, err = d.Exec("UPDATE OR IGNORE tagimplications SET implied = ? WHERE implied = ?", to, from)
+	if err != nil {
+		return errors.Wrap(err, "Failed to move implied tags")
+	}
+
+	// Delete the duplicates that were ignored above.
+	_, err = d.Exec(`
+		DELETE FROM tagimplications
+		WHERE  tagname = ? OR implied = ? OR tagname = implied`,
+		from, from,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete leftover tag implications")
+	}
+
+	// Keep the restriction of the to tag if it has one.
+	_, err = d.Exec("UPDATE OR IGNORE restrictedtags SET tagname = ? WHERE tagname = ?", to, from)
+	if err != nil {
+		return errors.Wrap(err, "Failed to move tag restriction")
+	}
+
+	// Compare the case too, since renaming may only change the case.
+	_, err = d.Exec("DELETE FROM restrictedtags WHERE tagname = ? COLLATE BINARY", from)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete leftover tag restriction")
+	}
+
+	_, err = d.Exec(`
+		UPDATE tagrevisions SET tagname = ?
+		WHERE  tagname = ? AND NOT EXISTS (SELECT 1 FROM tagrevisions WHERE tagname = ?)`,
+		to, from, to,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to move tag description")
+	}
+
+	return nil
+}
+
+// TagDescription returns the latest revision of the tag's description. If the
+// tag is an alias, then the description of its canonical tag is returned.
+func (d *Transaction) TagDescription(tag string) (*smolboard.TagRevision, error) {
+	if err := validTagName(tag); err != nil {
+		return nil, err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var rev smolboard.TagRevision
+
+	err = d.QueryRowx(
+		"SELECT * FROM tagrevisions WHERE tagname = ? ORDER BY id DESC LIMIT 1", tag,
+	).StructScan(&rev)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, smolboard.ErrTagDescriptionNotFound
+		}
+		return nil, errors.Wrap(err, "Failed to get tag description")
+	}
+
+	return &rev, nil
+}
+
+// TagRevisions returns all revisions of the tag's description, latest first.
+func (d *Transaction) TagRevisions(tag string) ([]smolboard.TagRevision, error) {
+	if err := validTagName(tag); err != nil {
+		return nil, err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := d.Queryx("SELECT * FROM tagrevisions WHERE tagname = ? ORDER BY id DESC", tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query tag revisions")
+	}
+
+	defer r.Close()
+
+	var revisions = []smolboard.TagRevision{}
+
+	for r.Next() {
+		var rev smolboard.TagRevision
+
+		if err := r.StructScan(&rev); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan tag revision")
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, nil
+}
+
+// SetTagDescription adds a revision with the new description to the tag. No
+// revision is added if the description is unchanged. Only trusted users can do
+// this.
+func (d *Transaction) SetTagDescription(tag, description string) (*smolboard.TagRevision, error) {
+	if err := validTagName(tag); err != nil {
+		return nil, err
+	}
+
+	if len(description) > smolboard.MaxTagDescriptionLen {
+		return nil, smolboard.ErrTagDescriptionTooLong
+	}
+
+	if err := d.HasPermission(smolboard.PermissionTrusted, true); err != nil {
+		return nil, err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := d.TagDescription(tag)
+	if err == nil && latest.Description == description {
+		return latest, nil
+	}
+	if err != nil && err != smolboard.ErrTagDescriptionNotFound {
+		return nil, err
+	}
+
+	var editor = d.Session.Username
+	var rev = smolboard.TagRevision{
+		ID:          int64(tagRevisionIDGen.Generate()),
+		TagName:     tag,
+		Description: description,
+		Editor:      &editor,
+	}
+
+	_, err = d.Exec(
+		"INSERT INTO tagrevisions VALUES (?, ?, ?, ?)",
+		rev.ID, rev.TagName, rev.Description, rev.Editor,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to insert tag revision")
+	}
+
+	return &rev, nil
+}
+
+// RelatedTags returns the tags that appear most often on the posts matching the
+// query that the user can see, excluding the tags in the query itself. The
+// count of each tag is the number of matching posts with it. Only the newest
+// MaxFacetPosts matching posts are counted.
+func (d *Transaction) RelatedTags(q smolboard.Query) ([]smolboard.PostTag, error) {
+	p, err := d.Permission()
+	if err != nil {
+		return nil, err
+	}
+
+	where, err := d.searchWhere(q, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.relatedTags(q, where)
+}
+
+// relatedTags returns the related tags of the posts matched by the built
+// query.
+func (d *Transaction) relatedTags(q smolboard.Query, where queryBuilder) ([]smolboard.PostTag, error) {
+	query := strings.Builder{}
+	query.WriteString(`
+		SELECT posttags.tagname, COUNT(1) AS count FROM posttags
+		WHERE  posttags.postid IN (SELECT posts.id `)
+	query.WriteString(where.String())
+	query.WriteString(" ORDER BY posts.id DESC LIMIT ?)")
+	args := append([]interface{}{}, where.args...)
+	args = append(args, smolboard.MaxFacetPosts)
+
+	if tags := queryTags(q.Expr); len(tags) > 0 {
+		query.WriteString(" AND posttags.tagname NOT IN (")
+
+		for i, tag := range tags {
+			// Posts are tagged with canonical tags, so aliases have to be
+			// resolved to be excluded.
+			canonical, err := d.canonicalTag(tag)
+			if err != nil {
+				return nil, err
+			}
+
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteByte('?')
+			args = append(args, canonical)
+		}
+
+		query.WriteString(")")
+	}
+
+	query.WriteString(`
+		GROUP BY posttags.tagname
+		ORDER BY count DESC, posttags.tagname ASC
+		LIMIT ?`)
+	args = append(args, smolboard.MaxRelatedTags)
+
+	r, err := d.Query(query.String(), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query related tags")
+	}
+
+	defer r.Close()
+
+	var tags = []smolboard.PostTag{}
+
+	for r.Next() {
+		var tag smolboard.PostTag
+
+		if err := r.Scan(&tag.TagName, &tag.Count); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan related tag")
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// RestrictedTags returns all restricted tags.
+func (d *Transaction) RestrictedTags() ([]smolboard.RestrictedTag, error) {
+	r, err := d.Queryx("SELECT * FROM restrictedtags ORDER BY tagname ASC")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query restricted tags")
+	}
+
+	defer r.Close()
+
+	var tags = []smolboard.RestrictedTag{}
+
+	for r.Next() {
+		var tag smolboard.RestrictedTag
+
+		if err := r.StructScan(&tag); err != nil {
+			return nil, errors.Wrap(err, "Failed to scan restricted tag")
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// SetRestrictedTag restricts the tag to users with at least the given
+// permission, which can't be higher than the current user's. Only
+// administrators can do this.
+func (d *Transaction) SetRestrictedTag(tag string, p smolboard.Permission) error {
+	if err := validTagName(tag); err != nil {
+		return err
+	}
+
+	if !p.IsValid() {
+		return smolboard.ErrInvalidPermission
+	}
+
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	// Don't let the user change a restriction they can't apply themselves.
+	if err := d.canApplyTag(tag); err != nil {
+		return err
+	}
+
+	if err := d.HasPermission(p, true); err != nil {
+		return err
+	}
+
+	_, err = d.Exec(`
+		INSERT INTO restrictedtags VALUES (?, ?)
+		ON CONFLICT (tagname) DO UPDATE SET permission = excluded.permission`,
+		tag, p,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to restrict tag")
+	}
+
+	return nil
+}
+
+// DeleteRestrictedTag removes the tag's restriction. Only administrators with
+// at least the tag's permission can do this.
+func (d *Transaction) DeleteRestrictedTag(tag string) error {
+	if err := validTagName(tag); err != nil {
+		return err
+	}
+
+	if err := d.HasPermission(smolboard.PermissionAdministrator, true); err != nil {
+		return err
+	}
+
+	tag, err := d.canonicalTag(tag)
+	if err != nil {
+		return err
+	}
+
+	if err := d.canApplyTag(tag); err != nil {
+		return err
+	}
+
+	r, err := d.Exec("DELETE FROM restrictedtags WHERE tagname = ?", tag)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete restricted tag")
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "Failed to get rows affected")
+	}
+
+	if count == 0 {
+		return smolboard.ErrRestrictedTagNotFound
+	}
+
+	return nil
+}
+
+// canApplyTag returns ErrTagRestricted if the tag is restricted to a higher
+// permission than the current user's.
+func (d *Transaction) canApplyTag(tag string) error {
+	var p smolboard.Permission
+
+	err := d.QueryRow("SELECT permission FROM restrictedtags WHERE tagname = ?", tag).Scan(&p)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return errors.Wrap(err, "Failed to get tag restriction")
+	}
+
+	if err := d.HasPermission(p, true); err != nil {
+		if errors.Is(err, smolboard.ErrActionNotPermitted) {
+			return smolboard.ErrTagRestricted
+		}
+		return err
+	}
+
+	return nil
+}
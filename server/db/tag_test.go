@@ -0,0 +1,1048 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-test/deep"
+)
+
+func TestTagCounts(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	var posts = make([]smolboard.Post, 3)
+
+	for i := range posts {
+		p := NewEmptyPost("image/png")
+		p.Size = 1
+
+		if err := tx.SavePost(&p); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+
+		// The first post has 1 tag, the second has 2 and so on.
+		for _, tag := range []string{"a", "b", "c"}[:i+1] {
+			if err := tx.TagPost(p.ID, tag); err != nil {
+				t.Fatal("Failed to tag post:", err)
+			}
+		}
+
+		posts[i] = p
+	}
+
+	expectCounts := func(t *testing.T, counts map[string]int) {
+		t.Helper()
+
+		tags, err := tx.SearchTag("")
+		if err != nil {
+			t.Fatal("Failed to search tags:", err)
+		}
+
+		var found = make(map[string]int, len(tags))
+		for _, tag := range tags {
+			found[tag.TagName] = tag.Count
+		}
+
+		if eq := deep.Equal(found, counts); eq != nil {
+			t.Fatal("Unexpected tag counts:", eq)
+		}
+
+		p, err := tx.Post(posts[len(posts)-1].ID)
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		for _, tag := range p.Tags {
+			if tag.Count != counts[tag.TagName] {
+				t.Fatalf("Unexpected count %d for post tag %q", tag.Count, tag.TagName)
+			}
+		}
+	}
+
+	expectCounts(t, map[string]int{"a": 3, "b": 2, "c": 1})
+
+	t.Run("Untag", func(t *testing.T) {
+		if err := tx.UntagPost(posts[2].ID, "a"); err != nil {
+			t.Fatal("Failed to untag post:", err)
+		}
+
+		expectCounts(t, map[string]int{"a": 2, "b": 2, "c": 1})
+	})
+
+	t.Run("DeletePost", func(t *testing.T) {
+		if err := tx.DeletePost(posts[1].ID); err != nil {
+			t.Fatal("Failed to delete post:", err)
+		}
+
+		expectCounts(t, map[string]int{"a": 1, "b": 1, "c": 1})
+	})
+
+	t.Run("Rebuild", func(t *testing.T) {
+		// Mess up the counts.
+		if _, err := tx.Exec("UPDATE tags SET count = 100 WHERE name = 'a'"); err != nil {
+			t.Fatal("Failed to change count:", err)
+		}
+		if _, err := tx.Exec("DELETE FROM tags WHERE name = 'b'"); err != nil {
+			t.Fatal("Failed to delete tag:", err)
+		}
+
+		if err := tx.RebuildTags(); err != nil {
+			t.Fatal("Failed to rebuild tags:", err)
+		}
+
+		expectCounts(t, map[string]int{"a": 1, "b": 1, "c": 1})
+	})
+}
+
+func TestRebuildTagsPermission(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	admin := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionAdministrator)
+
+	tx := testBeginTx(t, d, admin.AuthToken)
+
+	if err := tx.RebuildTags(); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error rebuilding tags as admin:", err)
+	}
+}
+
+func TestTagAliases(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	var posts = make([]smolboard.Post, 3)
+
+	for i, tags := range [][]string{{"kitty"}, {"cat", "kitty"}, {"dog"}} {
+		p := NewEmptyPost("image/png")
+		p.Size = 1
+
+		if err := tx.SavePost(&p); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+
+		for _, tag := range tags {
+			if err := tx.TagPost(p.ID, tag); err != nil {
+				t.Fatal("Failed to tag post:", err)
+			}
+		}
+
+		posts[i] = p
+	}
+
+	postTags := func(t *testing.T, id int64) []string {
+		t.Helper()
+
+		p, err := tx.Post(id)
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		var tags = make([]string, len(p.Tags))
+		for i, tag := range p.Tags {
+			tags[i] = tag.TagName
+		}
+
+		return tags
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		if err := tx.AddTagAlias("cat", "kitty"); err != nil {
+			t.Fatal("Failed to add alias:", err)
+		}
+
+		// Adding through another alias should point to the canonical tag.
+		if err := tx.AddTagAlias("kitty", "cats"); err != nil {
+			t.Fatal("Failed to add alias through alias:", err)
+		}
+
+		a, err := tx.TagAliases("cats")
+		if err != nil {
+			t.Fatal("Failed to get aliases:", err)
+		}
+
+		expect := []smolboard.TagAlias{
+			{Alias: "cats", TagName: "cat"},
+			{Alias: "kitty", TagName: "cat"},
+		}
+
+		if eq := deep.Equal(a, expect); eq != nil {
+			t.Fatal("Unexpected aliases:", eq)
+		}
+	})
+
+	t.Run("Retagged", func(t *testing.T) {
+		for _, post := range posts[:2] {
+			if eq := deep.Equal(postTags(t, post.ID), []string{"cat"}); eq != nil {
+				t.Fatal("Unexpected retagged tags:", eq)
+			}
+		}
+	})
+
+	t.Run("TagPost", func(t *testing.T) {
+		if err := tx.TagPost(posts[2].ID, "cats"); err != nil {
+			t.Fatal("Failed to tag post with alias:", err)
+		}
+
+		if eq := deep.Equal(postTags(t, posts[2].ID), []string{"cat", "dog"}); eq != nil {
+			t.Fatal("Unexpected tags:", eq)
+		}
+
+		if err := tx.TagPost(posts[2].ID, "kitty"); err != smolboard.ErrTagAlreadyAdded {
+			t.Fatal("Unexpected error tagging with another alias:", err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		r, err := tx.PostSearch("kitty", smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		if r.Total != 3 {
+			t.Fatal("Unexpected total searching alias:", r.Total)
+		}
+	})
+
+	t.Run("Untag", func(t *testing.T) {
+		if err := tx.UntagPost(posts[2].ID, "kitty"); err != nil {
+			t.Fatal("Failed to untag post with alias:", err)
+		}
+
+		if eq := deep.Equal(postTags(t, posts[2].ID), []string{"dog"}); eq != nil {
+			t.Fatal("Unexpected tags:", eq)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		var tests = []struct {
+			tag, alias string
+			err        error
+		}{
+			{"cat", "cat", smolboard.ErrTagAliasSelf},
+			{"cats", "cat", smolboard.ErrTagAliasSelf},
+			{"dog", "cat", smolboard.ErrTagAliasChained},
+			{"dog", "kitty", smolboard.ErrTagAliasExists},
+		}
+
+		for _, test := range tests {
+			if err := tx.AddTagAlias(test.tag, test.alias); err != test.err {
+				t.Errorf("Unexpected error aliasing %q to %q: %v", test.alias, test.tag, err)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := tx.DeleteTagAlias("dog", "kitty"); err != smolboard.ErrTagAliasNotFound {
+			t.Fatal("Unexpected error deleting alias of another tag:", err)
+		}
+
+		if err := tx.DeleteTagAlias("cat", "kitty"); err != nil {
+			t.Fatal("Failed to delete alias:", err)
+		}
+
+		r, err := tx.PostSearch("kitty", smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search:", err)
+		}
+
+		if r.Total != 0 {
+			t.Fatal("Unexpected total searching deleted alias:", r.Total)
+		}
+	})
+
+	t.Run("Relations", func(t *testing.T) {
+		if err := tx.AddTagImplication("puppy", "animal"); err != nil {
+			t.Fatal("Failed to add implication:", err)
+		}
+		if err := tx.SetRestrictedTag("puppy", smolboard.PermissionTrusted); err != nil {
+			t.Fatal("Failed to restrict tag:", err)
+		}
+
+		// The relations of the alias should move to the canonical tag instead
+		// of being left behind.
+		if err := tx.AddTagAlias("dog", "puppy"); err != nil {
+			t.Fatal("Failed to add alias:", err)
+		}
+
+		i, err := tx.TagImplications("dog")
+		if err != nil {
+			t.Fatal("Failed to get implications:", err)
+		}
+
+		expectImplications := []smolboard.TagImplication{
+			{TagName: "dog", Implied: "animal"},
+		}
+
+		if eq := deep.Equal(i, expectImplications); eq != nil {
+			t.Fatal("Unexpected implications:", eq)
+		}
+
+		r, err := tx.RestrictedTags()
+		if err != nil {
+			t.Fatal("Failed to get restricted tags:", err)
+		}
+
+		expectRestricted := []smolboard.RestrictedTag{
+			{TagName: "dog", Permission: smolboard.PermissionTrusted},
+		}
+
+		if eq := deep.Equal(r, expectRestricted); eq != nil {
+			t.Fatal("Unexpected restricted tags:", eq)
+		}
+	})
+}
+
+func TestTagAdminPermission(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionTrusted)
+
+	tx := testBeginTx(t, d, user.AuthToken)
+
+	if err := tx.AddTagAlias("cat", "kitty"); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error adding alias:", err)
+	}
+
+	if err := tx.DeleteTagAlias("cat", "kitty"); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error deleting alias:", err)
+	}
+
+	if err := tx.AddTagImplication("cat", "animal"); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error adding implication:", err)
+	}
+
+	if err := tx.DeleteTagImplication("cat", "animal"); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error deleting implication:", err)
+	}
+
+	if _, err := tx.BackfillTagImplications("cat"); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error backfilling implications:", err)
+	}
+
+	var category = smolboard.TagCategory{Name: "artist", Color: "#ff0000"}
+
+	if err := tx.SetTagCategory(category); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error setting category:", err)
+	}
+
+	if err := tx.DeleteTagCategory("artist"); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error deleting category:", err)
+	}
+}
+
+func TestTagImplications(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	newPost := func(t *testing.T, tags ...string) int64 {
+		t.Helper()
+		return testNewTaggedPost(t, tx, tags...)
+	}
+
+	postTags := func(t *testing.T, id int64) []string {
+		t.Helper()
+		return testPostTags(t, tx, id)
+	}
+
+	// Tagged before any implications exist.
+	var old = newPost(t, "siamese")
+
+	t.Run("Add", func(t *testing.T) {
+		var implications = [][2]string{
+			{"siamese", "cat"},
+			{"cat", "animal"},
+			{"cat", "feline"},
+		}
+
+		for _, i := range implications {
+			if err := tx.AddTagImplication(i[0], i[1]); err != nil {
+				t.Fatalf("Failed to imply %q from %q: %v", i[1], i[0], err)
+			}
+		}
+
+		i, err := tx.TagImplications("cat")
+		if err != nil {
+			t.Fatal("Failed to get implications:", err)
+		}
+
+		expect := []smolboard.TagImplication{
+			{TagName: "cat", Implied: "animal"},
+			{TagName: "cat", Implied: "feline"},
+		}
+
+		if eq := deep.Equal(i, expect); eq != nil {
+			t.Fatal("Unexpected implications:", eq)
+		}
+	})
+
+	t.Run("TagPost", func(t *testing.T) {
+		id := newPost(t, "siamese")
+
+		expect := []string{"animal", "cat", "feline", "siamese"}
+
+		if eq := deep.Equal(postTags(t, id), expect); eq != nil {
+			t.Fatal("Unexpected implied tags:", eq)
+		}
+
+		// Tagging a post that already has some implied tags should still work.
+		id = newPost(t, "feline", "siamese")
+
+		if eq := deep.Equal(postTags(t, id), expect); eq != nil {
+			t.Fatal("Unexpected implied tags:", eq)
+		}
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		var tests = []struct {
+			tag, implied string
+			err          error
+		}{
+			{"animal", "siamese", smolboard.ErrTagImplicationCycle},
+			{"cat", "siamese", smolboard.ErrTagImplicationCycle},
+			{"cat", "cat", smolboard.ErrTagImplicationCycle},
+			{"cat", "animal", smolboard.ErrTagImplicationExists},
+		}
+
+		for _, test := range tests {
+			if err := tx.AddTagImplication(test.tag, test.implied); err != test.err {
+				t.Errorf("Unexpected error implying %q from %q: %v", test.implied, test.tag, err)
+			}
+		}
+	})
+
+	t.Run("Backfill", func(t *testing.T) {
+		if eq := deep.Equal(postTags(t, old), []string{"siamese"}); eq != nil {
+			t.Fatal("Unexpected tags before backfilling:", eq)
+		}
+
+		// Backfilling the implied tag should also apply to the tags implying
+		// it.
+		n, err := tx.BackfillTagImplications("animal")
+		if err != nil {
+			t.Fatal("Failed to backfill:", err)
+		}
+
+		if n != 3 {
+			t.Fatal("Unexpected number of tags backfilled:", n)
+		}
+
+		expect := []string{"animal", "cat", "feline", "siamese"}
+
+		if eq := deep.Equal(postTags(t, old), expect); eq != nil {
+			t.Fatal("Unexpected tags after backfilling:", eq)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := tx.DeleteTagImplication("cat", "feline"); err != nil {
+			t.Fatal("Failed to delete implication:", err)
+		}
+
+		if err := tx.DeleteTagImplication("cat", "feline"); err != smolboard.ErrTagImplicationNotFound {
+			t.Fatal("Unexpected error deleting implication again:", err)
+		}
+
+		id := newPost(t, "siamese")
+
+		if eq := deep.Equal(postTags(t, id), []string{"animal", "cat", "siamese"}); eq != nil {
+			t.Fatal("Unexpected implied tags:", eq)
+		}
+	})
+}
+
+func TestTagCategories(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	var categories = []smolboard.TagCategory{
+		{Name: "character", Color: "#00aa00", SortOrder: 2},
+		{Name: "artist", Color: "#aa0000", SortOrder: 1},
+		{Name: "source", Color: "#0000aa", SortOrder: 3},
+	}
+
+	for _, category := range categories {
+		if err := tx.SetTagCategory(category); err != nil {
+			t.Fatal("Failed to set category:", err)
+		}
+	}
+
+	// Override the color of an existing category.
+	categories[0].Color = "#00ff00"
+
+	if err := tx.SetTagCategory(categories[0]); err != nil {
+		t.Fatal("Failed to override category:", err)
+	}
+
+	c, err := tx.TagCategories()
+	if err != nil {
+		t.Fatal("Failed to get categories:", err)
+	}
+
+	expect := []smolboard.TagCategory{categories[1], categories[0], categories[2]}
+
+	if eq := deep.Equal(c, expect); eq != nil {
+		t.Fatal("Unexpected categories:", eq)
+	}
+
+	p := NewEmptyPost("image/png")
+	p.Size = 1
+
+	if err := tx.SavePost(&p); err != nil {
+		t.Fatal("Failed to save post:", err)
+	}
+
+	for _, tag := range []string{"character:bar", "cat", "artist:foo", "meta:x", "artist:baz"} {
+		if err := tx.TagPost(p.ID, tag); err != nil {
+			t.Fatal("Failed to tag post:", err)
+		}
+	}
+
+	post, err := tx.Post(p.ID)
+	if err != nil {
+		t.Fatal("Failed to get post:", err)
+	}
+
+	var groups = map[string][]string{}
+	var order []string
+
+	for _, group := range post.TagGroups {
+		order = append(order, group.Category.Name)
+
+		for _, tag := range group.Tags {
+			groups[group.Category.Name] = append(groups[group.Category.Name], tag.TagName)
+		}
+	}
+
+	if eq := deep.Equal(order, []string{"artist", "character", ""}); eq != nil {
+		t.Fatal("Unexpected group order:", eq)
+	}
+
+	expectGroups := map[string][]string{
+		"artist":    {"artist:baz", "artist:foo"},
+		"character": {"character:bar"},
+		"":          {"cat", "meta:x"},
+	}
+
+	if eq := deep.Equal(groups, expectGroups); eq != nil {
+		t.Fatal("Unexpected tag groups:", eq)
+	}
+
+	t.Run("SearchNamespace", func(t *testing.T) {
+		var other = NewEmptyPost("image/png")
+		other.Size = 1
+
+		if err := tx.SavePost(&other); err != nil {
+			t.Fatal("Failed to save post:", err)
+		}
+
+		if err := tx.TagPost(other.ID, "artists:foo"); err != nil {
+			t.Fatal("Failed to tag post:", err)
+		}
+
+		r, err := tx.PostSearch("artist:*", smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search namespace:", err)
+		}
+
+		if len(r.Posts) != 1 || r.Posts[0].ID != p.ID {
+			t.Fatalf("Unexpected posts searching namespace: %#v", r.Posts)
+		}
+
+		r, err = tx.PostSearch("-character:*", smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search negated namespace:", err)
+		}
+
+		if len(r.Posts) != 1 || r.Posts[0].ID != other.ID {
+			t.Fatalf("Unexpected posts searching negated namespace: %#v", r.Posts)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := tx.DeleteTagCategory("artist"); err != nil {
+			t.Fatal("Failed to delete category:", err)
+		}
+
+		if err := tx.DeleteTagCategory("artist"); err != smolboard.ErrTagCategoryNotFound {
+			t.Fatal("Unexpected error deleting category again:", err)
+		}
+
+		post, err := tx.Post(p.ID)
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		if len(post.TagGroups) != 2 || len(post.TagGroups[1].Tags) != 4 {
+			t.Fatalf("Unexpected tag groups after deleting: %#v", post.TagGroups)
+		}
+	})
+}
+
+func TestRenameMergeTags(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	var (
+		p1 = testNewTaggedPost(t, tx, "catt", "cute")
+		p2 = testNewTaggedPost(t, tx, "catt", "Cat")
+		p3 = testNewTaggedPost(t, tx, "cat")
+	)
+
+	if err := tx.AddTagAlias("catt", "kat"); err != nil {
+		t.Fatal("Failed to add alias:", err)
+	}
+
+	if err := tx.AddTagImplication("catt", "animal"); err != nil {
+		t.Fatal("Failed to add implication:", err)
+	}
+
+	t.Run("Rename", func(t *testing.T) {
+		if err := tx.RenameTag("catt", "cat"); err != smolboard.ErrTagExists {
+			t.Fatal("Unexpected error renaming to used tag:", err)
+		}
+
+		if err := tx.RenameTag("dog", "doggo"); err != smolboard.ErrTagNotFound {
+			t.Fatal("Unexpected error renaming missing tag:", err)
+		}
+
+		if err := tx.RenameTag("cute", "kawaii"); err != nil {
+			t.Fatal("Failed to rename tag:", err)
+		}
+
+		if eq := deep.Equal(testPostTags(t, tx, p1), []string{"catt", "kawaii"}); eq != nil {
+			t.Fatal("Unexpected tags after renaming:", eq)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		if err := tx.MergeTags("catt", "kat"); err != smolboard.ErrTagMergeSelf {
+			t.Fatal("Unexpected error merging into alias:", err)
+		}
+
+		if err := tx.MergeTags("catt", "cat"); err != nil {
+			t.Fatal("Failed to merge tags:", err)
+		}
+
+		var expect = map[int64][]string{
+			p1: {"cat", "kawaii"},
+			// The post already has the tag in a different case.
+			p2: {"Cat"},
+			p3: {"cat"},
+		}
+
+		for id, tags := range expect {
+			if eq := deep.Equal(testPostTags(t, tx, id), tags); eq != nil {
+				t.Fatalf("Unexpected tags of post %d after merging: %v", id, eq)
+			}
+		}
+
+		a, err := tx.TagAliases("cat")
+		if err != nil {
+			t.Fatal("Failed to get aliases:", err)
+		}
+
+		if len(a) != 1 || a[0].Alias != "kat" {
+			t.Fatalf("Unexpected aliases after merging: %#v", a)
+		}
+
+		i, err := tx.TagImplications("cat")
+		if err != nil {
+			t.Fatal("Failed to get implications:", err)
+		}
+
+		if len(i) != 1 || i[0].Implied != "animal" {
+			t.Fatalf("Unexpected implications after merging: %#v", i)
+		}
+
+		r, err := tx.PostSearch("catt", smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatal("Failed to search merged tag:", err)
+		}
+
+		if r.Total != 0 {
+			t.Fatal("Unexpected total searching merged tag:", r.Total)
+		}
+	})
+}
+
+func TestRenameMergeTagsPermission(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionTrusted)
+
+	tx := testBeginTx(t, d, user.AuthToken)
+
+	if err := tx.RenameTag("cat", "kitty"); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error renaming tag:", err)
+	}
+
+	if err := tx.MergeTags("cat", "kitty"); err != smolboard.ErrActionNotPermitted {
+		t.Fatal("Unexpected error merging tags:", err)
+	}
+}
+
+// testNewTaggedPost saves a new post with the given tags and returns its ID.
+func testNewTaggedPost(t *testing.T, tx *Transaction, tags ...string) int64 {
+	t.Helper()
+
+	p := NewEmptyPost("image/png")
+	p.Size = 1
+
+	if err := tx.SavePost(&p); err != nil {
+		t.Fatal("Failed to save post:", err)
+	}
+
+	for _, tag := range tags {
+		if err := tx.TagPost(p.ID, tag); err != nil {
+			t.Fatalf("Failed to tag post with %q: %v", tag, err)
+		}
+	}
+
+	return p.ID
+}
+
+// testPostTags returns the tag names of the post.
+func testPostTags(t *testing.T, tx *Transaction, id int64) []string {
+	t.Helper()
+
+	p, err := tx.Post(id)
+	if err != nil {
+		t.Fatal("Failed to get post:", err)
+	}
+
+	var tags = make([]string, len(p.Tags))
+	for i, tag := range p.Tags {
+		tags[i] = tag.TagName
+	}
+
+	return tags
+}
+
+func TestTagDescriptions(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	t.Run("Permission", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		if _, err := tx.SetTagDescription("cat", "meow"); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error describing tag:", err)
+		}
+	})
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	if _, err := tx.TagDescription("cat"); err != smolboard.ErrTagDescriptionNotFound {
+		t.Fatal("Unexpected error getting missing description:", err)
+	}
+
+	var descriptions = []string{"A *small* animal.", "A **small** animal.", "A **small** animal."}
+
+	for _, desc := range descriptions {
+		if _, err := tx.SetTagDescription("cat", desc); err != nil {
+			t.Fatal("Failed to describe tag:", err)
+		}
+	}
+
+	if err := tx.AddTagAlias("cat", "kitty"); err != nil {
+		t.Fatal("Failed to add alias:", err)
+	}
+
+	rev, err := tx.TagDescription("kitty")
+	if err != nil {
+		t.Fatal("Failed to get description:", err)
+	}
+
+	if rev.TagName != "cat" || rev.Description != descriptions[1] {
+		t.Fatalf("Unexpected description: %#v", rev)
+	}
+
+	if rev.Editor == nil || *rev.Editor != owner.Username {
+		t.Fatalf("Unexpected editor: %v", rev.Editor)
+	}
+
+	revs, err := tx.TagRevisions("cat")
+	if err != nil {
+		t.Fatal("Failed to get revisions:", err)
+	}
+
+	// The unchanged description shouldn't add a revision.
+	if len(revs) != 2 || revs[0].Description != descriptions[1] || revs[1].Description != descriptions[0] {
+		t.Fatalf("Unexpected revisions: %#v", revs)
+	}
+
+	long := make([]byte, smolboard.MaxTagDescriptionLen+1)
+
+	if _, err := tx.SetTagDescription("cat", string(long)); err != smolboard.ErrTagDescriptionTooLong {
+		t.Fatal("Unexpected error setting long description:", err)
+	}
+
+	t.Run("Rename", func(t *testing.T) {
+		testNewTaggedPost(t, tx, "cat")
+
+		if err := tx.RenameTag("cat", "neko"); err != nil {
+			t.Fatal("Failed to rename tag:", err)
+		}
+
+		rev, err := tx.TagDescription("neko")
+		if err != nil {
+			t.Fatal("Failed to get renamed description:", err)
+		}
+
+		if rev.Description != descriptions[1] {
+			t.Fatalf("Unexpected renamed description: %#v", rev)
+		}
+	})
+}
+
+func TestRelatedTags(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		testNewTaggedPost(t, tx, "cat", "cute")
+		testNewTaggedPost(t, tx, "cat", "cute", "fluffy")
+		testNewTaggedPost(t, tx, "cat", "dog")
+		testNewTaggedPost(t, tx, "dog", "cute")
+
+		// The user can't see this post, so its tags shouldn't be counted.
+		hidden := testNewTaggedPost(t, tx, "cat", "fluffy")
+		if err := tx.SetPostPermission(hidden, smolboard.PermissionAdministrator); err != nil {
+			t.Fatal("Failed to hide post:", err)
+		}
+
+		if err := tx.AddTagAlias("cat", "kitty"); err != nil {
+			t.Fatal("Failed to add alias:", err)
+		}
+	})
+
+	tx := testBeginTx(t, d, user.AuthToken)
+
+	var tests = []struct {
+		query  string
+		expect []smolboard.PostTag
+	}{{
+		query: "kitty",
+		expect: []smolboard.PostTag{
+			{TagName: "cute", Count: 2},
+			{TagName: "dog", Count: 1},
+			{TagName: "fluffy", Count: 1},
+		},
+	}, {
+		query: "cute -fluffy",
+		expect: []smolboard.PostTag{
+			{TagName: "cat", Count: 1},
+			{TagName: "dog", Count: 1},
+		},
+	}, {
+		query:  "nothing",
+		expect: []smolboard.PostTag{},
+	}}
+
+	for _, test := range tests {
+		q, err := smolboard.ParsePostQuery(test.query)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", test.query, err)
+		}
+
+		tags, err := tx.RelatedTags(q)
+		if err != nil {
+			t.Fatalf("Failed to get related tags of %q: %v", test.query, err)
+		}
+
+		if eq := deep.Equal(tags, test.expect); eq != nil {
+			t.Errorf("Unexpected related tags of %q: %v", test.query, eq)
+		}
+	}
+}
+
+func TestRestrictedTags(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	admin := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionAdministrator)
+	trusted := newTestUser(t, d, owner.AuthToken, "ありかわ", smolboard.PermissionTrusted)
+	user := newTestUser(t, d, owner.AuthToken, "ひめ", smolboard.PermissionUser)
+
+	// The admin's post is tagged with a tag only the owner can apply.
+	var official int64
+
+	t.Run("Post", func(t *testing.T) {
+		official = testNewTaggedPost(t, testBeginTx(t, d, admin.AuthToken), "dog")
+	})
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		if err := tx.TagPost(official, "official"); err != nil {
+			t.Fatal("Failed to tag post:", err)
+		}
+
+		if err := tx.SetRestrictedTag("featured", smolboard.PermissionTrusted); err != nil {
+			t.Fatal("Failed to restrict tag:", err)
+		}
+		if err := tx.SetRestrictedTag("official", smolboard.PermissionOwner); err != nil {
+			t.Fatal("Failed to restrict tag:", err)
+		}
+		if err := tx.AddTagImplication("cat", "featured"); err != nil {
+			t.Fatal("Failed to add implication:", err)
+		}
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		tx := testBeginTx(t, d, admin.AuthToken)
+
+		if err := tx.SetRestrictedTag("mod", smolboard.PermissionOwner); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error restricting over own permission:", err)
+		}
+		if err := tx.SetRestrictedTag("official", smolboard.PermissionUser); err != smolboard.ErrTagRestricted {
+			t.Fatal("Unexpected error changing higher restriction:", err)
+		}
+		if err := tx.DeleteRestrictedTag("official"); err != smolboard.ErrTagRestricted {
+			t.Fatal("Unexpected error deleting higher restriction:", err)
+		}
+		if err := tx.DeleteRestrictedTag("nothing"); err != smolboard.ErrRestrictedTagNotFound {
+			t.Fatal("Unexpected error deleting missing restriction:", err)
+		}
+		if err := tx.SetRestrictedTag("mod", smolboard.PermissionAdministrator); err != nil {
+			t.Fatal("Failed to restrict tag:", err)
+		}
+
+		// Restrictions can be deleted through their aliases.
+		if err := tx.SetRestrictedTag("temp", smolboard.PermissionUser); err != nil {
+			t.Fatal("Failed to restrict tag:", err)
+		}
+		if err := tx.AddTagAlias("temp", "tmp"); err != nil {
+			t.Fatal("Failed to add alias:", err)
+		}
+		if err := tx.DeleteRestrictedTag("tmp"); err != nil {
+			t.Fatal("Failed to delete restriction by alias:", err)
+		}
+	})
+
+	t.Run("AdminTagChanges", func(t *testing.T) {
+		tx := testBeginTx(t, d, admin.AuthToken)
+
+		if err := tx.RenameTag("official", "staff"); err != smolboard.ErrTagRestricted {
+			t.Fatal("Unexpected error renaming higher restricted tag:", err)
+		}
+		if err := tx.MergeTags("official", "dog"); err != smolboard.ErrTagRestricted {
+			t.Fatal("Unexpected error merging higher restricted tag:", err)
+		}
+		if err := tx.MergeTags("dog", "official"); err != smolboard.ErrTagRestricted {
+			t.Fatal("Unexpected error merging into higher restricted tag:", err)
+		}
+		if err := tx.RevertPostTags(official, time.Time{}); err != smolboard.ErrTagRestricted {
+			t.Fatal("Unexpected error reverting higher restricted tag:", err)
+		}
+
+		if err := tx.AddTagImplication("dog", "mod"); err != nil {
+			t.Fatal("Failed to add implication:", err)
+		}
+		if err := tx.AddTagImplication("dog", "official"); err != nil {
+			t.Fatal("Failed to add implication:", err)
+		}
+
+		// Only the restricted tags that the admin can apply are backfilled.
+		n, err := tx.BackfillTagImplications("dog")
+		if err != nil {
+			t.Fatal("Failed to backfill implications:", err)
+		}
+
+		if n != 1 {
+			t.Fatal("Unexpected number of tags backfilled:", n)
+		}
+
+		expect := []string{"dog", "mod", "official"}
+
+		if eq := deep.Equal(testPostTags(t, tx, official), expect); eq != nil {
+			t.Fatal("Unexpected tags after backfilling:", eq)
+		}
+	})
+
+	t.Run("User", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		if err := tx.SetRestrictedTag("dog", smolboard.PermissionUser); err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error restricting as user:", err)
+		}
+
+		// The implied restricted tag is skipped.
+		id := testNewTaggedPost(t, tx, "cat")
+
+		if eq := deep.Equal(testPostTags(t, tx, id), []string{"cat"}); eq != nil {
+			t.Fatal("Unexpected tags:", eq)
+		}
+
+		if err := tx.TagPost(id, "FEATURED"); err != smolboard.ErrTagRestricted {
+			t.Fatal("Unexpected error adding restricted tag:", err)
+		}
+	})
+
+	t.Run("Trusted", func(t *testing.T) {
+		tx := testBeginTx(t, d, trusted.AuthToken)
+
+		id := testNewTaggedPost(t, tx, "cat")
+
+		if eq := deep.Equal(testPostTags(t, tx, id), []string{"cat", "featured"}); eq != nil {
+			t.Fatal("Unexpected tags:", eq)
+		}
+
+		if err := tx.TagPost(id, "mod"); err != smolboard.ErrTagRestricted {
+			t.Fatal("Unexpected error adding restricted tag:", err)
+		}
+
+		if err := tx.UntagPost(id, "featured"); err != nil {
+			t.Fatal("Failed to remove restricted tag:", err)
+		}
+	})
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	testNewTaggedPost(t, tx, "official")
+
+	if err := tx.RenameTag("official", "staff"); err != nil {
+		t.Fatal("Failed to rename tag:", err)
+	}
+
+	tags, err := tx.RestrictedTags()
+	if err != nil {
+		t.Fatal("Failed to get restricted tags:", err)
+	}
+
+	var expect = []smolboard.RestrictedTag{
+		{TagName: "featured", Permission: smolboard.PermissionTrusted},
+		{TagName: "mod", Permission: smolboard.PermissionAdministrator},
+		{TagName: "staff", Permission: smolboard.PermissionOwner},
+	}
+
+	if eq := deep.Equal(tags, expect); eq != nil {
+		t.Fatal("Unexpected restricted tags:", eq)
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/pkg/errors"
+)
+
+// VotePost sets the current user's vote on the post, which must be at least a
+// user. VoteNone removes the vote. The post's score is updated by triggers.
+func (d *Transaction) VotePost(postID int64, vote smolboard.Vote) error {
+	if !vote.IsValid() {
+		return smolboard.ErrInvalidVote
+	}
+
+	if err := d.HasPermission(smolboard.PermissionUser, true); err != nil {
+		return err
+	}
+
+	if _, err := d.PostQuickGet(postID); err != nil {
+		return err
+	}
+
+	if vote == smolboard.VoteNone {
+		_, err := d.Exec(
+			"DELETE FROM votes WHERE username = ? AND postid = ?",
+			d.Session.Username, postID,
+		)
+		if err != nil {
+			return errors.Wrap(err, "Failed to delete vote")
+		}
+
+		return nil
+	}
+
+	_, err := d.Exec(`
+		INSERT INTO votes VALUES (?, ?, ?)
+			ON CONFLICT (username, postid) DO UPDATE SET value = excluded.value`,
+		d.Session.Username, postID, vote,
+	)
+	if err != nil {
+		return errors.Wrap(err, "Failed to upsert vote")
+	}
+
+	return nil
+}
+
+// vote returns the current user's vote on the post.
+func (d *Transaction) vote(postID int64) (smolboard.Vote, error) {
+	var vote smolboard.Vote
+
+	err := d.QueryRow(
+		"SELECT value FROM votes WHERE username = ? AND postid = ?",
+		d.Session.Username, postID,
+	).Scan(&vote)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return smolboard.VoteNone, errors.Wrap(err, "Failed to scan vote")
+	}
+
+	return vote, nil
+}
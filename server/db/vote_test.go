@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-test/deep"
+)
+
+func TestVotes(t *testing.T) {
+	d := newTestDatabase(t)
+
+	owner := testNewOwner(t, d, "ひめありかわ", "password")
+	user := newTestUser(t, d, owner.AuthToken, "かぐやありかわ", smolboard.PermissionUser)
+
+	var cat, dog, bird int64
+
+	t.Run("Setup", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		cat = testNewTaggedPost(t, tx, "cat")
+		dog = testNewTaggedPost(t, tx, "dog")
+		bird = testNewTaggedPost(t, tx, "bird")
+
+		for id, vote := range map[int64]smolboard.Vote{
+			cat:  smolboard.VoteUp,
+			dog:  smolboard.VoteUp,
+			bird: smolboard.VoteDown,
+		} {
+			if err := tx.VotePost(id, vote); err != nil {
+				t.Fatal("Failed to vote:", err)
+			}
+		}
+	})
+
+	t.Run("Vote", func(t *testing.T) {
+		tx := testBeginTx(t, d, user.AuthToken)
+
+		if err := tx.VotePost(cat, 2); err != smolboard.ErrInvalidVote {
+			t.Fatal("Unexpected error with an invalid vote:", err)
+		}
+
+		if err := tx.VotePost(1, smolboard.VoteUp); err != smolboard.ErrPostNotFound {
+			t.Fatal("Unexpected error voting on an unknown post:", err)
+		}
+
+		// Change the vote on dog, so it should only count once.
+		for _, vote := range []smolboard.Vote{smolboard.VoteDown, smolboard.VoteUp} {
+			if err := tx.VotePost(dog, vote); err != nil {
+				t.Fatal("Failed to vote:", err)
+			}
+		}
+
+		if err := tx.VotePost(bird, smolboard.VoteDown); err != nil {
+			t.Fatal("Failed to vote:", err)
+		}
+
+		if err := tx.VotePost(cat, smolboard.VoteNone); err != nil {
+			t.Fatal("Failed to remove a missing vote:", err)
+		}
+
+		p, err := tx.Post(dog)
+		if err != nil {
+			t.Fatal("Failed to get post:", err)
+		}
+
+		if p.Score != 2 || p.Vote != smolboard.VoteUp {
+			t.Fatalf("Unexpected score and vote: %d, %d", p.Score, p.Vote)
+		}
+	})
+
+	t.Run("Guest", func(t *testing.T) {
+		err := d.AcquireGuest(context.TODO(), func(tx *Transaction) error {
+			return tx.VotePost(cat, smolboard.VoteUp)
+		})
+		if err != smolboard.ErrActionNotPermitted {
+			t.Fatal("Unexpected error voting as guest:", err)
+		}
+	})
+
+	testSearch := func(t *testing.T, tx *Transaction, query string, expect []int64) {
+		t.Helper()
+
+		s, err := tx.PostSearch(query, smolboard.Cursor{}, 25, 0)
+		if err != nil {
+			t.Fatalf("Failed to search %q: %v", query, err)
+		}
+
+		var ids = make([]int64, len(s.Posts))
+		for i, p := range s.Posts {
+			ids[i] = p.ID
+		}
+
+		if eq := deep.Equal(ids, expect); eq != nil {
+			t.Fatalf("Unexpected posts searching %q: %v", query, eq)
+		}
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		tx := testBeginTx(t, d, owner.AuthToken)
+
+		testSearch(t, tx, "order:score", []int64{dog, cat, bird})
+		testSearch(t, tx, "score:>1", []int64{dog})
+		testSearch(t, tx, "score:<0", []int64{bird})
+		testSearch(t, tx, "score:-2", []int64{bird})
+
+		if err := tx.DeleteUser(user.Username); err != nil {
+			t.Fatal("Failed to delete user:", err)
+		}
+	})
+
+	tx := testBeginTx(t, d, owner.AuthToken)
+
+	// Deleting the user should remove their votes from the scores.
+	testSearch(t, tx, "score:1", []int64{dog, cat})
+	testSearch(t, tx, "score:-1", []int64{bird})
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/diamondburned/smolboard/server/http/internal/limit"
 	"github.com/diamondburned/smolboard/server/http/internal/limread"
 	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/http/pool"
 	"github.com/diamondburned/smolboard/server/http/post"
+	"github.com/diamondburned/smolboard/server/http/tag"
 	"github.com/diamondburned/smolboard/server/http/token"
 	"github.com/diamondburned/smolboard/server/http/upload"
 	"github.com/diamondburned/smolboard/server/http/upload/imgsrv"
@@ -85,6 +87,12 @@ func New(db *db.Database, cfg HTTPConfig) (*Routes, error) {
 	mux.Mount("/tokens", token.Mount(m))
 	mux.Mount("/images", imgsrv.Mount(m))
 	mux.Mount("/posts", post.Mount(m))
+	mux.Mount("/comments", post.MountComments(m))
+	mux.Mount("/search", post.MountSearch(m))
+	mux.Mount("/pools", pool.Mount(m))
+	mux.Mount("/tags", tag.Mount(m))
+	mux.Mount("/categories", tag.MountCategories(m))
+	mux.Mount("/restrictedtags", tag.MountRestricted(m))
 	mux.Mount("/users", user.Mount(m))
 
 	return rts, nil
@@ -11,6 +11,7 @@ import (
 	"github.com/diamondburned/smolboard/server/httperr"
 	"github.com/diamondburned/smolboard/smolboard"
 	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
 )
 
 type Request struct {
@@ -164,6 +165,11 @@ func RenderError(w http.ResponseWriter, err error) {
 		Error: err.Error(),
 	}
 
+	var dup smolboard.ErrDuplicatePost
+	if errors.As(err, &dup) {
+		jsonError.PostID = dup.PostID
+	}
+
 	if err := json.NewEncoder(w).Encode(jsonError); err != nil {
 		log.Println("Encode failed:", err)
 	}
@@ -0,0 +1,184 @@
+package pool
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/diamondburned/smolboard/server/http/internal/form"
+	"github.com/diamondburned/smolboard/server/http/internal/limit"
+	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-chi/chi"
+)
+
+func Mount(m tx.Middlewarer) http.Handler {
+	mux := chi.NewMux()
+	mux.Use(limit.RateLimit(32))
+	mux.Get("/", m(ListPools))
+	mux.Post("/", m(CreatePool))
+
+	mux.Route("/{id}", func(r chi.Router) {
+		r.Get("/", m(GetPool))
+		r.Patch("/", m(EditPool))
+		r.Delete("/", m(DeletePool))
+
+		r.Put("/order", m(ReorderPool))
+
+		r.Put("/posts/{postID}", m(AddPost))
+		r.Delete("/posts/{postID}", m(RemovePost))
+	})
+
+	return mux
+}
+
+// poolID returns the pool ID in the URL.
+func poolID(r tx.Request) (int64, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return 0, smolboard.ErrPoolNotFound
+	}
+	return i, nil
+}
+
+// ListParams is the URL parameter for pool listing pagination.
+type ListParams struct {
+	Count uint `schema:"c"`
+	Page  uint `schema:"p"`
+}
+
+func ListPools(r tx.Request) (interface{}, error) {
+	var params = ListParams{Count: 25}
+
+	if err := form.Unmarshal(r, &params); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return r.Tx.Pools(params.Count, params.Page)
+}
+
+type CreateParams struct {
+	Name        string               `schema:"name,required"`
+	Description string               `schema:"description"`
+	Permission  smolboard.Permission `schema:"p"` // default Guest
+}
+
+func CreatePool(r tx.Request) (interface{}, error) {
+	var p CreateParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return r.Tx.CreatePool(p.Name, p.Description, p.Permission)
+}
+
+// GetParams is the URL parameter for getting a pool.
+type GetParams struct {
+	// ShowBlacklisted overrides the user's tag blacklist.
+	ShowBlacklisted bool `schema:"showblacklisted"`
+}
+
+func GetPool(r tx.Request) (interface{}, error) {
+	i, err := poolID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var params GetParams
+
+	if err := form.Unmarshal(r, &params); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	r.Tx.ShowBlacklisted = params.ShowBlacklisted
+
+	return r.Tx.Pool(i)
+}
+
+// EditParams is the form for editing a pool. Fields that aren't given are left
+// unchanged.
+type EditParams struct {
+	Name        *string               `schema:"name"`
+	Description *string               `schema:"description"`
+	Permission  *smolboard.Permission `schema:"p"`
+}
+
+func EditPool(r tx.Request) (interface{}, error) {
+	i, err := poolID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var p EditParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.EditPool(i, smolboard.PoolEdit{
+		Name:        p.Name,
+		Description: p.Description,
+		Permission:  p.Permission,
+	})
+}
+
+func DeletePool(r tx.Request) (interface{}, error) {
+	i, err := poolID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, r.Tx.DeletePool(i)
+}
+
+type OrderParams struct {
+	PostIDs []int64 `schema:"id"`
+}
+
+// ReorderPool: /{id}/order?id=1&id=2
+func ReorderPool(r tx.Request) (interface{}, error) {
+	i, err := poolID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var p OrderParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.ReorderPool(i, p.PostIDs)
+}
+
+func AddPost(r tx.Request) (interface{}, error) {
+	i, err := poolID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, err := strconv.ParseInt(r.Param("postID"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	// Users may add posts they chose to see despite the blacklist.
+	r.Tx.ShowBlacklisted = true
+
+	return nil, r.Tx.AddPoolPost(i, postID)
+}
+
+func RemovePost(r tx.Request) (interface{}, error) {
+	i, err := poolID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	postID, err := strconv.ParseInt(r.Param("postID"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotInPool
+	}
+
+	return nil, r.Tx.RemovePoolPost(i, postID)
+}
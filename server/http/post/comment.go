@@ -0,0 +1,80 @@
+package post
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/diamondburned/smolboard/server/http/internal/form"
+	"github.com/diamondburned/smolboard/server/http/internal/limit"
+	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-chi/chi"
+)
+
+// MountComments mounts the comments by their IDs. Comments are listed and
+// added under their posts instead.
+func MountComments(m tx.Middlewarer) http.Handler {
+	mux := chi.NewMux()
+	mux.Use(limit.RateLimit(32))
+	mux.Delete("/{id}", m(DeleteComment))
+
+	return mux
+}
+
+// CommentListParams is the URL parameter for comment listing pagination.
+type CommentListParams struct {
+	Count uint `schema:"c"`
+	Page  uint `schema:"p"`
+	// ShowBlacklisted overrides the user's tag blacklist.
+	ShowBlacklisted bool `schema:"showblacklisted"`
+}
+
+func ListComments(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	var params = CommentListParams{Count: 25}
+
+	if err := form.Unmarshal(r, &params); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	r.Tx.ShowBlacklisted = params.ShowBlacklisted
+
+	return r.Tx.Comments(i, params.Count, params.Page)
+}
+
+type CommentParams struct {
+	Body string `schema:"body,required"`
+}
+
+func AddComment(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	var p CommentParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	// The blacklist only hides posts, so it shouldn't stop users from replying
+	// to the post they chose to see.
+	r.Tx.ShowBlacklisted = true
+
+	return r.Tx.AddComment(i, p.Body)
+}
+
+func DeleteComment(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrCommentNotFound
+	}
+
+	return nil, r.Tx.DeleteComment(i)
+}
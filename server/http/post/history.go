@@ -0,0 +1,60 @@
+package post
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/diamondburned/smolboard/server/http/internal/form"
+	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/diamondburned/smolboard/smolboard"
+)
+
+func GetHistory(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	var params GetParams
+
+	if err := form.Unmarshal(r, &params); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	r.Tx.ShowBlacklisted = params.ShowBlacklisted
+
+	return r.Tx.PostHistory(i)
+}
+
+type RevertParams struct {
+	// Time is the time to revert to in RFC3339.
+	Time string `schema:"t,required"`
+}
+
+// RevertTags reverts the post's tags and returns the reverted post.
+func RevertTags(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	var p RevertParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, p.Time)
+	if err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid time")
+	}
+
+	if err := r.Tx.RevertPostTags(i, t); err != nil {
+		return nil, err
+	}
+
+	r.Tx.ShowBlacklisted = true
+
+	return r.Tx.Post(i)
+}
@@ -19,11 +19,13 @@ func Mount(m tx.Middlewarer) http.Handler {
 	mux.Get("/", m(ListPosts))
 	// POST but parse form before entering a transaction.
 	mux.With(preparseMultipart, limit.RateLimit(2)).Post("/", m(UploadPost))
+	mux.Post("/batch", m(EditPosts))
 
 	mux.Route("/{id}", func(r chi.Router) {
 		// GET gives both tags and permission.
 		r.Get("/", m(GetPost))
 		r.Delete("/", m(DeletePost))
+		r.Patch("/", m(EditPost))
 
 		r.Patch("/permission", m(SetPostPermission))
 
@@ -32,6 +34,22 @@ func Mount(m tx.Middlewarer) http.Handler {
 			r.Post("/", m(TagPost))
 			r.Delete("/", m(UntagPost))
 		})
+
+		r.Put("/favorite", m(FavoritePost))
+		r.Delete("/favorite", m(UnfavoritePost))
+
+		r.Put("/vote", m(VotePost))
+		r.Delete("/vote", m(UnvotePost))
+
+		r.Route("/comments", func(r chi.Router) {
+			r.Get("/", m(ListComments))
+			r.With(limit.RateLimit(8)).Post("/", m(AddComment))
+		})
+
+		r.Route("/history", func(r chi.Router) {
+			r.Get("/", m(GetHistory))
+			r.Post("/revert", m(RevertTags))
+		})
 	})
 
 	return mux
@@ -53,6 +71,17 @@ type ListParams struct {
 	Query string `schema:"q"`
 	Count uint   `schema:"c"`
 	Page  uint   `schema:"p"`
+	// Order overrides the order term in the query if it's not empty.
+	Order string `schema:"o"`
+	// Before and After are the post IDs to paginate from. The page is counted
+	// from them if either is given.
+	Before int64 `schema:"before"`
+	After  int64 `schema:"after"`
+	// ShowBlacklisted overrides the user's tag blacklist.
+	ShowBlacklisted bool `schema:"showblacklisted"`
+	// Facets fills in the related tags of the results. They are never counted
+	// for pages other than the first.
+	Facets bool `schema:"facets"`
 }
 
 func ListPosts(r tx.Request) (interface{}, error) {
@@ -62,7 +91,46 @@ func ListPosts(r tx.Request) (interface{}, error) {
 		return nil, httperr.Wrap(err, 400, "Invalid form")
 	}
 
-	return r.Tx.PostSearch(params.Query, params.Count, params.Page)
+	q, err := smolboard.ParsePostQuery(params.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Order != "" {
+		q.Order, err = smolboard.ParseOrder(params.Order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.Tx.ShowBlacklisted = params.ShowBlacklisted
+
+	var cursor = smolboard.Cursor{
+		Before: params.Before,
+		After:  params.After,
+	}
+
+	results, err := r.Tx.PostSearchQuery(q, cursor, params.Count, params.Page)
+	if err != nil {
+		return nil, err
+	}
+
+	var firstPage = cursor.Before == 0 && cursor.After == 0 && params.Page == 0
+
+	if params.Facets && firstPage && len(results.Posts) > 0 {
+		results.Facets, err = r.Tx.RelatedTags(q)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// GetParams is the URL parameter for getting a post.
+type GetParams struct {
+	// ShowBlacklisted overrides the user's tag blacklist.
+	ShowBlacklisted bool `schema:"showblacklisted"`
 }
 
 func GetPost(r tx.Request) (interface{}, error) {
@@ -71,11 +139,29 @@ func GetPost(r tx.Request) (interface{}, error) {
 		return nil, smolboard.ErrPostNotFound
 	}
 
+	var params GetParams
+
+	if err := form.Unmarshal(r, &params); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	r.Tx.ShowBlacklisted = params.ShowBlacklisted
+
 	return r.Tx.Post(i)
 }
 
 type UploadParams struct {
 	Permission smolboard.Permission `schema:"p"` // default Normal
+	// Tags are added to all uploaded posts along with their implied tags.
+	Tags []string `schema:"t"`
+	// Title, Description and Source are set on all uploaded posts.
+	Title       string `schema:"title"`
+	Description string `schema:"description"`
+	Source      string `schema:"source"`
+	// LinkDuplicates returns the existing post in place of a file that was
+	// already uploaded instead of failing with ErrDuplicatePost. The existing
+	// post is left unchanged, and it must be visible to the user.
+	LinkDuplicates bool `schema:"linkduplicates"`
 }
 
 func UploadPost(r tx.Request) (interface{}, error) {
@@ -85,6 +171,22 @@ func UploadPost(r tx.Request) (interface{}, error) {
 		return nil, httperr.Wrap(err, 400, "Invalid form")
 	}
 
+	// Fast path: validate tags before downloading anything.
+	for _, tag := range p.Tags {
+		if err := smolboard.TagIsValid(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	err := smolboard.PostEditIsValid(smolboard.PostEdit{
+		Title:       &p.Title,
+		Description: &p.Description,
+		Source:      &p.Source,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	files, ok := r.MultipartForm.File["file"]
 	if !ok {
 		return nil, httperr.New(400, "missing field 'file' in form")
@@ -95,20 +197,57 @@ func UploadPost(r tx.Request) (interface{}, error) {
 		return nil, err
 	}
 
-	for _, post := range posts {
+	// Results are separate from the downloaded posts, since linked duplicates
+	// must not be cleaned up.
+	var results = make([]smolboard.UploadedPost, len(posts))
+
+	for i, post := range posts {
 		// Set the post's permission.
 		post.Permission = p.Permission
+		post.Title = p.Title
+		post.Description = p.Description
+		post.Source = p.Source
 
 		if err := r.Tx.SavePost(post); err != nil {
+			var dup smolboard.ErrDuplicatePost
+			if p.LinkDuplicates && errors.As(err, &dup) {
+				if existing, err := r.Tx.PostQuickGet(dup.PostID); err == nil {
+					r.Up.CleanupPost(*post)
+					results[i].Post = *existing
+					continue
+				}
+			}
+
 			// Something failed. Before we exit, we need to clean up all
 			// downloaded files.
 			r.Up.CleanupPosts(posts)
 
 			return nil, errors.Wrap(err, "Failed to save post")
 		}
+
+		// Warn about posts that look almost the same.
+		near, err := nearDuplicates(r, post)
+		if err != nil {
+			r.Up.CleanupPosts(posts)
+			return nil, errors.Wrap(err, "Failed to find near-duplicates")
+		}
+
+		for _, tag := range p.Tags {
+			// Implied tags may have already added this tag.
+			err := r.Tx.TagPost(post.ID, tag)
+			if err != nil && !errors.Is(err, smolboard.ErrTagAlreadyAdded) {
+				r.Up.CleanupPosts(posts)
+				return nil, errors.Wrap(err, "Failed to tag post")
+			}
+		}
+
+		results[i] = smolboard.UploadedPost{
+			Post:           *post,
+			NearDuplicates: near,
+		}
 	}
 
-	return posts, nil
+	return results, nil
 }
 
 func DeletePost(r tx.Request) (interface{}, error) {
@@ -117,6 +256,10 @@ func DeletePost(r tx.Request) (interface{}, error) {
 		return nil, smolboard.ErrPostNotFound
 	}
 
+	// The blacklist only hides posts, so it shouldn't stop them from being
+	// deleted.
+	r.Tx.ShowBlacklisted = true
+
 	p, err := r.Tx.Post(i)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to query post")
@@ -131,6 +274,59 @@ func DeletePost(r tx.Request) (interface{}, error) {
 	return nil, nil
 }
 
+// BatchParams is the form for editing multiple posts at once.
+type BatchParams struct {
+	PostIDs    []int64               `schema:"id,required"`
+	AddTags    []string              `schema:"t"`
+	RemoveTags []string              `schema:"u"`
+	Permission *smolboard.Permission `schema:"p"`
+}
+
+// EditPosts returns the result of each post in the same order.
+func EditPosts(r tx.Request) (interface{}, error) {
+	var p BatchParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return r.Tx.EditPosts(smolboard.PostBatch{
+		PostIDs:    p.PostIDs,
+		AddTags:    p.AddTags,
+		RemoveTags: p.RemoveTags,
+		Permission: p.Permission,
+	})
+}
+
+// EditParams is the form for editing a post's text fields and parent. Fields
+// that aren't given are left unchanged. A parent of 0 removes the parent.
+type EditParams struct {
+	Title       *string `schema:"title"`
+	Description *string `schema:"description"`
+	Source      *string `schema:"source"`
+	Parent      *int64  `schema:"parent"`
+}
+
+func EditPost(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	var p EditParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.EditPost(i, smolboard.PostEdit{
+		Title:       p.Title,
+		Description: p.Description,
+		Source:      p.Source,
+		Parent:      p.Parent,
+	})
+}
+
 type PostPermission struct {
 	Permission smolboard.Permission `schema:"p,required"`
 }
@@ -151,6 +347,62 @@ func SetPostPermission(r tx.Request) (interface{}, error) {
 	return nil, r.Tx.SetPostPermission(i, p.Permission)
 }
 
+func FavoritePost(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	// Users may favorite posts they chose to see despite the blacklist.
+	r.Tx.ShowBlacklisted = true
+
+	return nil, r.Tx.FavoritePost(i)
+}
+
+func UnfavoritePost(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	r.Tx.ShowBlacklisted = true
+
+	return nil, r.Tx.UnfavoritePost(i)
+}
+
+type VoteParams struct {
+	Vote smolboard.Vote `schema:"v,required"`
+}
+
+// VotePost: /{id}/vote?v=1
+func VotePost(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	var p VoteParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	r.Tx.ShowBlacklisted = true
+
+	return nil, r.Tx.VotePost(i, p.Vote)
+}
+
+func UnvotePost(r tx.Request) (interface{}, error) {
+	i, err := strconv.ParseInt(r.Param("id"), 10, 64)
+	if err != nil {
+		return nil, smolboard.ErrPostNotFound
+	}
+
+	r.Tx.ShowBlacklisted = true
+
+	return nil, r.Tx.VotePost(i, smolboard.VoteNone)
+}
+
 type Tag struct {
 	Tag string `schema:"t,required"`
 }
@@ -0,0 +1,84 @@
+package post
+
+import (
+	"net/http"
+
+	"github.com/diamondburned/smolboard/server/http/internal/form"
+	"github.com/diamondburned/smolboard/server/http/internal/limit"
+	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-chi/chi"
+)
+
+// MountSearch mounts the searches for posts that aren't done with a query.
+func MountSearch(m tx.Middlewarer) http.Handler {
+	mux := chi.NewMux()
+	// Hashing the image may shell out to FFmpeg, so limit it like uploading.
+	mux.With(preparseMultipart, limit.RateLimit(2)).Post("/image", m(SearchImage))
+
+	return mux
+}
+
+type SearchImageParams struct {
+	Count uint `schema:"c"`
+	// Distance is the maximum distance between the perceptual hashes.
+	Distance int `schema:"d"`
+	// ShowBlacklisted overrides the user's tag blacklist.
+	ShowBlacklisted bool `schema:"showblacklisted"`
+}
+
+// SearchImage returns the visible posts that look like the uploaded image,
+// most similar first.
+func SearchImage(r tx.Request) (interface{}, error) {
+	var p = SearchImageParams{
+		Count:    25,
+		Distance: smolboard.DefaultSimilarDistance,
+	}
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	files := r.MultipartForm.File["file"]
+	if len(files) != 1 {
+		return nil, httperr.New(400, "expected one field 'file' in form")
+	}
+
+	h, err := r.Up.PerceptualHash(files[0])
+	if err != nil {
+		return nil, err
+	}
+
+	r.Tx.ShowBlacklisted = p.ShowBlacklisted
+
+	return r.Tx.SimilarPosts(h, p.Distance, p.Count)
+}
+
+// maxNearDuplicates is the maximum number of near-duplicates returned for each
+// uploaded post.
+const maxNearDuplicates = 5
+
+// nearDuplicates returns the IDs of the visible posts that look almost the same
+// as the post.
+func nearDuplicates(r tx.Request, post *smolboard.Post) ([]int64, error) {
+	if post.PHash == nil {
+		return nil, nil
+	}
+
+	// Query one more, since the post itself is included.
+	s, err := r.Tx.SimilarPosts(*post.PHash, smolboard.NearDuplicateDistance, maxNearDuplicates+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+
+	for _, similar := range s {
+		if similar.ID != post.ID && len(ids) < maxNearDuplicates {
+			ids = append(ids, similar.ID)
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,51 @@
+package tag
+
+import (
+	"net/http"
+
+	"github.com/diamondburned/smolboard/server/http/internal/form"
+	"github.com/diamondburned/smolboard/server/http/internal/limit"
+	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-chi/chi"
+)
+
+// MountCategories mounts the tag categories. They're not under the tags route,
+// as category names would collide with tag names.
+func MountCategories(m tx.Middlewarer) http.Handler {
+	mux := chi.NewMux()
+	mux.Use(limit.RateLimit(32))
+	mux.Get("/", m(ListCategories))
+	mux.Put("/{name}", m(SetCategory))
+	mux.Delete("/{name}", m(DeleteCategory))
+
+	return mux
+}
+
+func ListCategories(r tx.Request) (interface{}, error) {
+	return r.Tx.TagCategories()
+}
+
+type Category struct {
+	Color     string `schema:"c,required"`
+	SortOrder int    `schema:"o"`
+}
+
+func SetCategory(r tx.Request) (interface{}, error) {
+	var c Category
+
+	if err := form.Unmarshal(r, &c); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.SetTagCategory(smolboard.TagCategory{
+		Name:      r.Param("name"),
+		Color:     c.Color,
+		SortOrder: c.SortOrder,
+	})
+}
+
+func DeleteCategory(r tx.Request) (interface{}, error) {
+	return nil, r.Tx.DeleteTagCategory(r.Param("name"))
+}
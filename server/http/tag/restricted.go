@@ -0,0 +1,46 @@
+package tag
+
+import (
+	"net/http"
+
+	"github.com/diamondburned/smolboard/server/http/internal/form"
+	"github.com/diamondburned/smolboard/server/http/internal/limit"
+	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/diamondburned/smolboard/smolboard"
+	"github.com/go-chi/chi"
+)
+
+// MountRestricted mounts the restricted tags. Like categories, they're not
+// under the tags route so that the list doesn't collide with tag names.
+func MountRestricted(m tx.Middlewarer) http.Handler {
+	mux := chi.NewMux()
+	mux.Use(limit.RateLimit(32))
+	mux.Get("/", m(ListRestricted))
+	mux.Put("/{name}", m(SetRestricted))
+	mux.Delete("/{name}", m(DeleteRestricted))
+
+	return mux
+}
+
+func ListRestricted(r tx.Request) (interface{}, error) {
+	return r.Tx.RestrictedTags()
+}
+
+type Restriction struct {
+	Permission smolboard.Permission `schema:"p,required"`
+}
+
+func SetRestricted(r tx.Request) (interface{}, error) {
+	var p Restriction
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.SetRestrictedTag(tagName(r), p.Permission)
+}
+
+func DeleteRestricted(r tx.Request) (interface{}, error) {
+	return nil, r.Tx.DeleteRestrictedTag(tagName(r))
+}
@@ -0,0 +1,189 @@
+package tag
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/diamondburned/smolboard/server/http/internal/form"
+	"github.com/diamondburned/smolboard/server/http/internal/limit"
+	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/go-chi/chi"
+)
+
+func Mount(m tx.Middlewarer) http.Handler {
+	mux := chi.NewMux()
+	mux.Use(limit.RateLimit(32))
+	mux.Get("/", m(SearchTags))
+
+	mux.Route("/{name}", func(r chi.Router) {
+		r.Patch("/", m(RenameTag))
+		r.Post("/merge", m(MergeTags))
+
+		r.Get("/description", m(GetDescription))
+		r.Put("/description", m(SetDescription))
+		r.Get("/revisions", m(ListRevisions))
+
+		r.Route("/aliases", func(r chi.Router) {
+			r.Get("/", m(ListAliases))
+			r.Put("/", m(AddAlias))
+			r.Post("/", m(AddAlias))
+			r.Delete("/", m(DeleteAlias))
+		})
+
+		r.Route("/implications", func(r chi.Router) {
+			r.Get("/", m(ListImplications))
+			r.Put("/", m(AddImplication))
+			r.Post("/", m(AddImplication))
+			r.Delete("/", m(DeleteImplication))
+			r.Post("/backfill", m(BackfillImplications))
+		})
+	})
+
+	return mux
+}
+
+// tagName returns the tag name in the URL.
+func tagName(r tx.Request) string {
+	var name = r.Param("name")
+
+	// Chi routes with the raw path if the path has escaped characters that
+	// can't be decoded back, such as slashes.
+	if r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+	}
+
+	return name
+}
+
+// SearchParams is the URL parameter for searching tags.
+type SearchParams struct {
+	// Query is the prefix of the tags to search for. An empty query returns
+	// the most used tags.
+	Query string `schema:"q"`
+}
+
+func SearchTags(r tx.Request) (interface{}, error) {
+	var params SearchParams
+
+	if err := form.Unmarshal(r, &params); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return r.Tx.SearchTag(params.Query)
+}
+
+type Rename struct {
+	Name string `schema:"n,required"`
+}
+
+func RenameTag(r tx.Request) (interface{}, error) {
+	var n Rename
+
+	if err := form.Unmarshal(r, &n); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.RenameTag(tagName(r), n.Name)
+}
+
+type Merge struct {
+	Into string `schema:"t,required"`
+}
+
+// MergeTags merges the tag in the URL into the tag in the form.
+func MergeTags(r tx.Request) (interface{}, error) {
+	var m Merge
+
+	if err := form.Unmarshal(r, &m); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.MergeTags(tagName(r), m.Into)
+}
+
+func GetDescription(r tx.Request) (interface{}, error) {
+	return r.Tx.TagDescription(tagName(r))
+}
+
+type Description struct {
+	Description string `schema:"d"`
+}
+
+// SetDescription returns the new revision.
+func SetDescription(r tx.Request) (interface{}, error) {
+	var d Description
+
+	if err := form.Unmarshal(r, &d); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return r.Tx.SetTagDescription(tagName(r), d.Description)
+}
+
+func ListRevisions(r tx.Request) (interface{}, error) {
+	return r.Tx.TagRevisions(tagName(r))
+}
+
+type Alias struct {
+	Alias string `schema:"a,required"`
+}
+
+func ListAliases(r tx.Request) (interface{}, error) {
+	return r.Tx.TagAliases(tagName(r))
+}
+
+func AddAlias(r tx.Request) (interface{}, error) {
+	var a Alias
+
+	if err := form.Unmarshal(r, &a); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.AddTagAlias(tagName(r), a.Alias)
+}
+
+func DeleteAlias(r tx.Request) (interface{}, error) {
+	var a Alias
+
+	if err := form.Unmarshal(r, &a); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.DeleteTagAlias(tagName(r), a.Alias)
+}
+
+type Implication struct {
+	Implied string `schema:"t,required"`
+}
+
+func ListImplications(r tx.Request) (interface{}, error) {
+	return r.Tx.TagImplications(tagName(r))
+}
+
+func AddImplication(r tx.Request) (interface{}, error) {
+	var i Implication
+
+	if err := form.Unmarshal(r, &i); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.AddTagImplication(tagName(r), i.Implied)
+}
+
+func DeleteImplication(r tx.Request) (interface{}, error) {
+	var i Implication
+
+	if err := form.Unmarshal(r, &i); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	return nil, r.Tx.DeleteTagImplication(tagName(r), i.Implied)
+}
+
+// BackfillImplications returns the number of tags added.
+func BackfillImplications(r tx.Request) (interface{}, error) {
+	return r.Tx.BackfillTagImplications(tagName(r))
+}
@@ -2,6 +2,8 @@
 package atomdl
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"os"
 	"path/filepath"
@@ -10,15 +12,39 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Download writes the reader into the post's file in the directory. It sets the
+// post's size and the SHA-256 hash of its content, which is computed while the
+// file is written.
 func Download(r io.Reader, dir string, p *smolboard.Post) error {
-	t, n, err := download(r, dir, p.Filename())
+	h := sha256.New()
+
+	t, n, err := download(io.TeeReader(r, h), dir, p.Filename())
 	if err != nil {
 		os.Remove(t)
 	}
 	p.Size = n
+	p.Hash = hex.EncodeToString(h.Sum(nil))
 	return err
 }
 
+// HashFile returns the SHA-256 hash of the file's content, which is the same
+// hash that Download sets.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to open file")
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrap(err, "Failed to read file")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func download(r io.Reader, dir, file string) (tmpname string, n int64, err error) {
 	tmpname = filepath.Join(dir, "."+file)
 
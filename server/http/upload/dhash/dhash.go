@@ -0,0 +1,32 @@
+// Package dhash computes difference hashes, which are perceptual hashes of
+// images. Images that look alike have hashes that differ by only a few bits,
+// even if they're resized or recompressed.
+package dhash
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// Hash returns the 64-bit difference hash of the image. Each bit is set if a
+// pixel is darker than the pixel on its right in the 9x8 grayscale image.
+func Hash(img image.Image) uint64 {
+	gray := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+
+	var hash uint64
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			// The image is gray, so only the red channel is needed.
+			l := gray.Pix[gray.PixOffset(x, y)]
+			r := gray.Pix[gray.PixOffset(x+1, y)]
+
+			if l < r {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+
+	return hash
+}
@@ -0,0 +1,41 @@
+package dhash
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// testGradient returns an image that gets brighter to the right.
+func testGradient(w, h int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x * 255 / w)})
+		}
+	}
+
+	return img
+}
+
+func TestHash(t *testing.T) {
+	var gradient = Hash(testGradient(900, 800))
+
+	if gradient != 1<<64-1 {
+		t.Fatalf("Unexpected hash of gradient: %016x", gradient)
+	}
+
+	// Resizing shouldn't change the hash much.
+	if d := bits.OnesCount64(gradient ^ Hash(testGradient(90, 40))); d > 4 {
+		t.Fatal("Unexpected distance after resizing:", d)
+	}
+
+	// Mirroring makes the image get darker to the right instead.
+	if d := bits.OnesCount64(gradient ^ Hash(imaging.FlipH(testGradient(900, 800)))); d != 64 {
+		t.Fatal("Unexpected distance after mirroring:", d)
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/diamondburned/smolboard/server/http/upload/ff"
 	"github.com/diamondburned/smolboard/server/http/upload/imgsrv/thumbcache"
 	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/diamondburned/smolboard/smolboard"
 	"github.com/disintegration/imaging"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -54,7 +55,7 @@ func Mount(m tx.Middlewarer) http.Handler {
 func ServePost(r tx.Request) (interface{}, error) {
 	id, name := getStored(r)
 
-	p, err := r.Tx.PostQuickGet(id)
+	p, err := getPost(r, id)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +67,7 @@ func ServePost(r tx.Request) (interface{}, error) {
 		// If the user requested post's extension is different from what we
 		// have, then we do a permanent redirection to the correct filename.
 		if filename := p.Filename(); filename != name {
-			redirect := path.Dir(r.URL.Path) + "/" + filename
+			redirect := withQuery(r, path.Dir(r.URL.Path)+"/"+filename)
 			// Cache the redirect for this specific endpoint.
 			http.Redirect(w, r.Request, redirect, http.StatusPermanentRedirect)
 
@@ -95,7 +96,7 @@ func ServePost(r tx.Request) (interface{}, error) {
 func ServeThumbnail(r tx.Request) (interface{}, error) {
 	id, _ := getStored(r)
 
-	p, err := r.Tx.PostQuickGet(id)
+	p, err := getPost(r, id)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +109,7 @@ func ServeThumbnail(r tx.Request) (interface{}, error) {
 		if err := serveThumbnail(w, r, name); err != nil {
 			log.Printf("Error serving thumbnail %q: %v\n", name, err)
 
-			redirect := path.Dir(r.URL.Path) // remove /thumb
+			redirect := withQuery(r, path.Dir(r.URL.Path)) // remove /thumb
 			http.Redirect(w, r.Request, redirect, http.StatusPermanentRedirect)
 		}
 
@@ -117,6 +118,22 @@ func ServeThumbnail(r tx.Request) (interface{}, error) {
 	}, nil
 }
 
+// getPost gets the post to be served. The user's tag blacklist applies unless
+// the showblacklisted query is given.
+func getPost(r tx.Request, id int64) (*smolboard.Post, error) {
+	r.Tx.ShowBlacklisted, _ = strconv.ParseBool(r.URL.Query().Get("showblacklisted"))
+	return r.Tx.PostQuickGet(id)
+}
+
+// withQuery appends the request's query to the redirect path, so that
+// overrides are kept after redirecting.
+func withQuery(r tx.Request, redirect string) string {
+	if r.URL.RawQuery != "" {
+		redirect += "?" + r.URL.RawQuery
+	}
+	return redirect
+}
+
 var jpegOpts = &jpeg.Options{
 	Quality: 95,
 }
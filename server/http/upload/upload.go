@@ -2,7 +2,9 @@ package upload
 
 import (
 	"fmt"
+	"image"
 	"io"
+	"io/ioutil"
 	"log"
 	"mime/multipart"
 	"os"
@@ -13,6 +15,7 @@ import (
 	"github.com/diamondburned/smolboard/server/db"
 	"github.com/diamondburned/smolboard/server/http/internal/limread"
 	"github.com/diamondburned/smolboard/server/http/upload/atomdl"
+	"github.com/diamondburned/smolboard/server/http/upload/dhash"
 	"github.com/diamondburned/smolboard/server/http/upload/ff"
 	"github.com/diamondburned/smolboard/server/http/upload/imgsrv/thumbcache"
 	"github.com/diamondburned/smolboard/server/httperr"
@@ -95,6 +98,11 @@ func (c UploadConfig) CleanupPosts(posts []*smolboard.Post) {
 	}()
 }
 
+// HashPost returns the SHA-256 hash of the post's file.
+func (c UploadConfig) HashPost(post smolboard.Post) (string, error) {
+	return atomdl.HashFile(filepath.Join(c.FileDirectory, post.Filename()))
+}
+
 func (c UploadConfig) CreatePosts(headers []*multipart.FileHeader) ([]*smolboard.Post, error) {
 	if len(headers) > MaxFiles {
 		return nil, ErrTooManyFiles
@@ -175,6 +183,9 @@ func (c UploadConfig) createPost(header *multipart.FileHeader) (*smolboard.Post,
 		p.Attributes.Width = bounds.Dx()
 		p.Attributes.Height = bounds.Dy()
 
+		ph := int64(dhash.Hash(i))
+		p.PHash = &ph
+
 		// Resize the image using a rough algorithm.
 		i = imaging.Fit(i, 50, 50, imaging.Box)
 
@@ -191,8 +202,14 @@ func (c UploadConfig) createPost(header *multipart.FileHeader) (*smolboard.Post,
 			p.Attributes.Height = s.Height
 		}
 
-		i, err := ff.FirstFrame(downloaded, 50, 50, ff.NeighborScaler)
+		i, err := firstFrame(downloaded)
 		if err == nil {
+			ph := int64(dhash.Hash(i))
+			p.PHash = &ph
+
+			// Resize the frame the same way as images.
+			i = imaging.Fit(i, 50, 50, imaging.Box)
+
 			h, err := blurhash.Encode(4, 3, i)
 			if err == nil {
 				p.Attributes.Blurhash = h
@@ -203,6 +220,53 @@ func (c UploadConfig) createPost(header *multipart.FileHeader) (*smolboard.Post,
 	return &p, nil
 }
 
+// hashFrameSize is the size that the first frames of videos are fit in before
+// they're hashed. It's large enough for dhash to average the pixels itself as
+// it does for images, so a video hashes close to a screenshot of it.
+const hashFrameSize = 256
+
+// firstFrame returns the first frame of the video smoothly resized for hashing.
+func firstFrame(path string) (image.Image, error) {
+	return ff.FirstFrame(path, hashFrameSize, hashFrameSize, ff.LanczosScaler)
+}
+
+// PerceptualHash computes the perceptual hash of the file the same way as
+// uploaded posts without saving it.
+func (c UploadConfig) PerceptualHash(header *multipart.FileHeader) (int64, error) {
+	f, err := header.Open()
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to open file header")
+	}
+	defer f.Close()
+
+	r, err := c.WrapReader(f)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to create a new reader")
+	}
+
+	// FFmpeg needs a file, so copy the upload into a temporary one.
+	t, err := ioutil.TempFile("", "smolboard-search-*")
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to create temporary file")
+	}
+	defer os.Remove(t.Name())
+	defer t.Close()
+
+	if _, err := io.Copy(t, r); err != nil {
+		return 0, errors.Wrap(err, "Failed to write temporary file")
+	}
+
+	i, err := imaging.Open(t.Name(), imaging.AutoOrientation(true))
+	if err != nil {
+		i, err = firstFrame(t.Name())
+		if err != nil {
+			return 0, ErrUnsupportedType{r.CType}
+		}
+	}
+
+	return int64(dhash.Hash(i)), nil
+}
+
 // WrapReader wraps the given reader and restrict its MIME type as well as
 // file size.
 func (c UploadConfig) WrapReader(r io.Reader) (*limread.LimitedReader, error) {
@@ -0,0 +1,62 @@
+package user
+
+import (
+	"github.com/diamondburned/smolboard/server/http/internal/form"
+	"github.com/diamondburned/smolboard/server/http/internal/tx"
+	"github.com/diamondburned/smolboard/server/httperr"
+	"github.com/diamondburned/smolboard/smolboard"
+)
+
+type BlacklistParams struct {
+	Tag string `schema:"t,required"`
+}
+
+// GetBlacklist returns the current user's tag blacklist.
+func GetBlacklist(r tx.Request) (interface{}, error) {
+	// Only allow @me.
+	if username(r) != r.Tx.Session.Username {
+		return nil, smolboard.ErrActionNotPermitted
+	}
+
+	return r.Tx.Blacklist()
+}
+
+// AddBlacklist adds a tag into the current user's blacklist and returns the
+// new blacklist.
+func AddBlacklist(r tx.Request) (interface{}, error) {
+	if username(r) != r.Tx.Session.Username {
+		return nil, smolboard.ErrActionNotPermitted
+	}
+
+	var p BlacklistParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	if err := r.Tx.AddBlacklist(p.Tag); err != nil {
+		return nil, err
+	}
+
+	return r.Tx.Blacklist()
+}
+
+// RemoveBlacklist removes a tag from the current user's blacklist and returns
+// the new blacklist.
+func RemoveBlacklist(r tx.Request) (interface{}, error) {
+	if username(r) != r.Tx.Session.Username {
+		return nil, smolboard.ErrActionNotPermitted
+	}
+
+	var p BlacklistParams
+
+	if err := form.Unmarshal(r, &p); err != nil {
+		return nil, httperr.Wrap(err, 400, "Invalid form")
+	}
+
+	if err := r.Tx.RemoveBlacklist(p.Tag); err != nil {
+		return nil, err
+	}
+
+	return r.Tx.Blacklist()
+}
@@ -26,6 +26,13 @@ func Mount(m tx.Middlewarer) http.Handler {
 
 		r.Patch("/permission", m(PromoteUser))
 
+		r.Route("/blacklist", func(r chi.Router) { // only @me
+			r.Get("/", m(GetBlacklist))
+			r.Put("/", m(AddBlacklist))
+			r.Post("/", m(AddBlacklist))
+			r.Delete("/", m(RemoveBlacklist))
+		})
+
 		r.Route("/sessions", func(r chi.Router) {
 			r.Get("/", m(GetSessions))
 			r.Delete("/", m(DeleteAllSessions))
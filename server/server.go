@@ -1,8 +1,12 @@
 package server
 
 import (
+	"log"
+	"os"
+
 	"github.com/diamondburned/smolboard/server/db"
 	"github.com/diamondburned/smolboard/server/http"
+	"github.com/diamondburned/smolboard/smolboard"
 	"github.com/pkg/errors"
 )
 
@@ -42,6 +46,24 @@ func CreateOwner(config Config, password []byte) error {
 	return db.CreateOwner(config.DBConfig, string(password))
 }
 
+// RebuildTags recounts all tags in the database.
+func RebuildTags(config Config) error {
+	return db.RebuildTags(config.DBConfig)
+}
+
+// HashPosts hashes the files of the posts uploaded before their content was
+// hashed. Posts with missing files are skipped.
+func HashPosts(config Config) (int, error) {
+	return db.HashPosts(config.DBConfig, func(p smolboard.Post) (string, error) {
+		h, err := config.UploadConfig.HashPost(p)
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("Skipping post %d with missing file %q", p.ID, p.Filename())
+			return "", nil
+		}
+		return h, err
+	})
+}
+
 type App struct {
 	*http.Routes
 	Database *db.Database
@@ -0,0 +1,1069 @@
+package smolboard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/diamondburned/smolboard/server/httperr"
+)
+
+// Query represents the parsed query string. A zero-value Query searches for
+// nothing and thus will list all posts.
+type Query struct {
+	// Expr is the root of the parsed expression tree. It is nil if the query
+	// has no terms.
+	Expr QueryExpr
+	// Poster is the user that all searched posts must belong to. It is only
+	// set if the user term is at the top level, that is, it's not inside a
+	// group nor negated.
+	Poster string
+	// Order is the order of the searched posts. It is not part of Expr, as it
+	// does not filter posts.
+	Order Order
+}
+
+// QueryExpr is a node in the expression tree of a parsed query. It is one of
+// QueryAnd, QueryOr, QueryNot, QueryTag, QueryNamespace, QueryPoster or a
+// qualifier term such as QueryType, QueryMIME, QueryText, QueryFavorite,
+// QueryPool, QueryParent, QueryHash, QueryCompare or QueryDate.
+type QueryExpr interface {
+	// String encodes the expression back to the query syntax.
+	String() string
+	queryExpr()
+}
+
+// QueryAnd matches posts that match all of its expressions.
+type QueryAnd []QueryExpr
+
+// QueryOr matches posts that match any of its expressions.
+type QueryOr []QueryExpr
+
+// QueryNot matches posts that do not match its expression.
+type QueryNot struct {
+	Expr QueryExpr
+}
+
+// QueryTag matches posts that have the tag.
+type QueryTag string
+
+// QueryNamespace matches posts that have any tag in the namespace, such as
+// "artist" for "artist:*".
+type QueryNamespace string
+
+// QueryPoster matches posts uploaded by the user.
+type QueryPoster string
+
+// QueryType matches posts whose MIME type has the given top-level type, such
+// as "image" or "video".
+type QueryType string
+
+// QueryMIME matches posts with exactly the given MIME type.
+type QueryMIME string
+
+// QueryText matches posts whose title, description or source has all the words
+// in the text.
+type QueryText string
+
+// QueryFavorite matches posts favorited by the user.
+type QueryFavorite string
+
+// QueryPool matches posts in the pool with the ID.
+type QueryPool int64
+
+// QueryParent matches the children of the post with the ID.
+type QueryParent int64
+
+// QueryHash matches posts with the SHA-256 hash in lowercase hexadecimal.
+type QueryHash string
+
+// QueryOp is the comparison operator in qualifier terms such as "size:>10MB".
+type QueryOp string
+
+const (
+	OpEqual        QueryOp = "="
+	OpLess         QueryOp = "<"
+	OpLessEqual    QueryOp = "<="
+	OpGreater      QueryOp = ">"
+	OpGreaterEqual QueryOp = ">="
+)
+
+// queryOps contains all operators, longest first for prefix matching.
+var queryOps = []QueryOp{OpLessEqual, OpGreaterEqual, OpLess, OpGreater, OpEqual}
+
+// QueryField is a numeric attribute of a post that can be compared.
+type QueryField string
+
+const (
+	// QueryFieldSize is the post's file size in bytes.
+	QueryFieldSize QueryField = "size"
+	// QueryFieldWidth is the post's width in pixels.
+	QueryFieldWidth QueryField = "width"
+	// QueryFieldHeight is the post's height in pixels.
+	QueryFieldHeight QueryField = "height"
+	// QueryFieldRatio is the post's width divided by its height.
+	QueryFieldRatio QueryField = "ratio"
+	// QueryFieldScore is the post's upvotes minus its downvotes. It is the only
+	// field that can be negative.
+	QueryFieldScore QueryField = "score"
+)
+
+// QueryCompare matches posts whose field compares true to the value with the
+// operator.
+type QueryCompare struct {
+	Field QueryField
+	Op    QueryOp
+	Value float64
+}
+
+// QueryDate matches posts created before or after the given time. After is
+// inclusive, while before is exclusive.
+type QueryDate struct {
+	Before bool
+	Time   time.Time
+}
+
+func (QueryAnd) queryExpr()       {}
+func (QueryOr) queryExpr()        {}
+func (QueryNot) queryExpr()       {}
+func (QueryTag) queryExpr()       {}
+func (QueryNamespace) queryExpr() {}
+func (QueryPoster) queryExpr()    {}
+func (QueryType) queryExpr()      {}
+func (QueryMIME) queryExpr()      {}
+func (QueryText) queryExpr()      {}
+func (QueryFavorite) queryExpr()  {}
+func (QueryPool) queryExpr()      {}
+func (QueryParent) queryExpr()    {}
+func (QueryHash) queryExpr()      {}
+func (QueryCompare) queryExpr()   {}
+func (QueryDate) queryExpr()      {}
+
+func (q QueryAnd) String() string {
+	var strs = make([]string, len(q))
+	for i, expr := range q {
+		// AND binds tighter than OR, so we'll have to explicitly group ORs.
+		strs[i] = groupExpr(expr, true)
+	}
+	return strings.Join(strs, " ")
+}
+
+func (q QueryOr) String() string {
+	var strs = make([]string, len(q))
+	for i, expr := range q {
+		strs[i] = groupExpr(expr, false)
+	}
+	return strings.Join(strs, " OR ")
+}
+
+func (q QueryNot) String() string {
+	return "-" + groupExpr(q.Expr, true)
+}
+
+func (q QueryTag) String() string {
+	return EscapeTag(string(q))
+}
+
+func (q QueryNamespace) String() string {
+	return string(q) + ":*"
+}
+
+func (q QueryPoster) String() string {
+	return "@" + string(q)
+}
+
+func (q QueryType) String() string {
+	return "type:" + string(q)
+}
+
+func (q QueryMIME) String() string {
+	return "mime:" + string(q)
+}
+
+func (q QueryText) String() string {
+	if tagNeedsQuotes(string(q)) {
+		return "text:'" + wordEscaper.Replace(string(q)) + "'"
+	}
+	return "text:" + string(q)
+}
+
+func (q QueryFavorite) String() string {
+	return "fav:" + string(q)
+}
+
+func (q QueryPool) String() string {
+	return "pool:" + strconv.FormatInt(int64(q), 10)
+}
+
+func (q QueryParent) String() string {
+	return "parent:" + strconv.FormatInt(int64(q), 10)
+}
+
+func (q QueryHash) String() string {
+	return "hash:" + string(q)
+}
+
+func (q QueryCompare) String() string {
+	var op = q.Op
+	if op == OpEqual {
+		op = ""
+	}
+
+	var value string
+	if q.Field == QueryFieldSize {
+		value = datasize.ByteSize(q.Value).String()
+	} else {
+		value = strconv.FormatFloat(q.Value, 'f', -1, 64)
+	}
+
+	return string(q.Field) + ":" + string(op) + value
+}
+
+func (q QueryDate) String() string {
+	var key = "after:"
+	if q.Before {
+		key = "before:"
+	}
+
+	// Use the short form if the time is exactly a date.
+	if t := q.Time.UTC(); t.Equal(t.Truncate(24 * time.Hour)) {
+		return key + t.Format(queryDateLayout)
+	}
+
+	return key + q.Time.Format(time.RFC3339)
+}
+
+// groupExpr wraps the expression in parentheses if it is an OR group, or if
+// it's an AND group and and is true.
+func groupExpr(expr QueryExpr, and bool) string {
+	switch expr.(type) {
+	case QueryOr:
+		return "(" + expr.String() + ")"
+	case QueryAnd:
+		if and {
+			return "(" + expr.String() + ")"
+		}
+	}
+	return expr.String()
+}
+
+// QueryTagLimit is the maximum number of tags allowed in a single query.
+const QueryTagLimit = 1024
+
+var (
+	ErrQueryAlreadyHasUser  = httperr.New(400, "search query already has a user filter")
+	ErrQueryHasTooMayTags   = httperr.New(400, "search query has too many tags")
+	ErrQueryUnbalanced      = httperr.New(400, "search query has unbalanced parentheses")
+	ErrQueryUnclosedQuote   = httperr.New(400, "search query has an unclosed quote")
+	ErrQueryEmptyExpr       = httperr.New(400, "search query has an empty expression")
+	ErrQueryAlreadyHasOrder = httperr.New(400, "search query already has an order")
+	ErrQueryNegatedOrder    = httperr.New(400, "search query order cannot be negated")
+)
+
+// ErrQueryInvalidValue is returned when a qualifier term such as "size:x" has
+// an invalid value.
+type ErrQueryInvalidValue struct {
+	Key   string
+	Value string
+}
+
+func (err ErrQueryInvalidValue) StatusCode() int {
+	return 400
+}
+
+func (err ErrQueryInvalidValue) Error() string {
+	return fmt.Sprintf("search query has an invalid %s value %q", err.Key, err.Value)
+}
+
+// AllPosts searches for all posts; it is a zero value instance of PostQuery.
+var AllPosts = Query{}
+
+// ParsePostQuery parses a search string to query the post gallery. The syntax
+// is space-delimited optionally quoted tags with an optional prefix in front to
+// indicate a post author. Terms next to each other must all match, while terms
+// separated by OR only need one to match. Terms can be grouped with
+// parentheses, and a term or a group prefixed with a minus sign (or NOT) is
+// excluded from the results. Only one post author may appear in each group.
+//
+// Unquoted terms in the form of "key:value" with a known key are qualifiers
+// that filter on the post's metadata instead of its tags. These are type (e.g.
+// "video"), mime, size (e.g. ">10MB"), width, height, ratio (e.g. "16:9"),
+// score (e.g. ">=-5"), after and before (e.g. "2006-01-02"), text, which
+// searches the posts' titles, descriptions and sources, fav, which matches
+// posts favorited by the given user, pool, which matches posts in the pool with
+// the given ID, parent, which matches the children of the post with the given
+// ID, and hash, which matches posts with the given SHA-256 hash. Numeric
+// qualifiers may have an operator before the value. Only the value may be
+// quoted, such as "text:'two words'". Other unquoted terms in the form of
+// "namespace:*" match posts with any tag in the namespace, such as "artist:*".
+//
+// An optional order term such as "order:oldest" changes the order of the
+// results; refer to Order for the possible values. Below is an example:
+//
+//	tag1 "tag with space" (cat OR dog) -excluded -(a b) @diamondburned
+//	type:image width:>=1920 ratio:16:9 after:2020-01-01 text:'sunset beach'
+//	order:largest
+func ParsePostQuery(q string) (Query, error) {
+	// Fast path.
+	if q == "" {
+		return AllPosts, nil
+	}
+
+	tokens, err := tokenizeQuery(q)
+	if err != nil {
+		return AllPosts, err
+	}
+
+	// Queries with only spaces are treated as empty.
+	if len(tokens) == 0 {
+		return AllPosts, nil
+	}
+
+	p := queryParser{tokens: tokens}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return AllPosts, err
+	}
+
+	// The only way for the parser to stop early is on a closing parenthesis
+	// without a matching one.
+	if p.pos < len(p.tokens) {
+		return AllPosts, ErrQueryUnbalanced
+	}
+
+	var query = Query{Expr: expr}
+
+	if p.order != nil {
+		query.Order = *p.order
+	}
+
+	switch expr := expr.(type) {
+	case QueryPoster:
+		query.Poster = string(expr)
+	case QueryAnd:
+		for _, expr := range expr {
+			if poster, ok := expr.(QueryPoster); ok {
+				query.Poster = string(poster)
+			}
+		}
+	}
+
+	return query, nil
+}
+
+// String encodes the parsed Query to a regular string query.
+func (q Query) String() string {
+	var order string
+	if !q.Order.IsZero() {
+		order = orderPrefix + q.Order.String()
+	}
+
+	switch {
+	case q.Expr == nil:
+		return order
+	case order == "":
+		return q.Expr.String()
+	default:
+		// The order term can be anywhere, even in the last OR group.
+		return q.Expr.String() + " " + order
+	}
+}
+
+type queryTokenKind uint8
+
+const (
+	queryWord queryTokenKind = iota
+	queryOpen
+	queryClose
+	queryMinus
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	word string
+	// quoted is true if the word has any quotes or escapes in it. Quoted words
+	// are never keywords or user terms.
+	quoted bool
+	// quoteAt is the byte offset in the word where the first quote or escape
+	// is.
+	quoteAt int
+}
+
+// quotedValue returns true if the word is only quoted after its first colon,
+// such as "text:'two words'", which is a qualifier with a quoted value.
+func (t queryToken) quotedValue() bool {
+	colon := strings.IndexByte(t.word, ':')
+	return t.quoted && colon > -1 && colon < t.quoteAt
+}
+
+// isKeyword returns true if the token is the given unquoted keyword.
+func (t queryToken) isKeyword(keyword string) bool {
+	return t.kind == queryWord && !t.quoted && t.word == keyword
+}
+
+// orderValue returns the value of the token if it's an order term.
+func (t queryToken) orderValue() (string, bool) {
+	if t.kind != queryWord || t.quoted || !strings.HasPrefix(t.word, orderPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(t.word, orderPrefix), true
+}
+
+// tokenizeQuery splits the query into words and symbols. Opening parentheses
+// and minus signs are only symbols at the start of a word, and closing
+// parentheses are only symbols if they're not balanced within the word, so
+// tags like "saber_(fate)" work without quoting.
+func tokenizeQuery(q string) ([]queryToken, error) {
+	var runes = []rune(q)
+	var tokens []queryToken
+
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: queryOpen})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: queryClose})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, queryToken{kind: queryMinus})
+			i++
+		default:
+			tok, n, err := readQueryWord(runes[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i += n
+		}
+	}
+
+	return tokens, nil
+}
+
+// readQueryWord reads a single word token and returns the number of runes
+// consumed.
+func readQueryWord(runes []rune) (queryToken, int, error) {
+	var word strings.Builder
+	var tok = queryToken{kind: queryWord}
+
+	var quote rune
+	var parens int
+
+	var i int
+
+Loop:
+	for ; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			// Escaped rune; take the next one literally.
+			i++
+			tok.quote(word.Len())
+			word.WriteRune(runes[i])
+
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+
+		case r == '\'' || r == '"':
+			quote = r
+			tok.quote(word.Len())
+
+		case unicode.IsSpace(r):
+			break Loop
+
+		case r == ')' && parens == 0:
+			// Unbalanced closing parenthesis; leave it for the group.
+			break Loop
+
+		default:
+			switch r {
+			case '(':
+				parens++
+			case ')':
+				parens--
+			}
+			word.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return tok, i, ErrQueryUnclosedQuote
+	}
+
+	tok.word = word.String()
+	return tok, i, nil
+}
+
+// quote marks the token as quoted at the given offset if it isn't yet.
+func (t *queryToken) quote(at int) {
+	if !t.quoted {
+		t.quoted = true
+		t.quoteAt = at
+	}
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	terms  int
+	order  *Order
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *queryParser) next() *queryToken {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+// parseOr parses AND groups separated by the OR keyword.
+func (p *queryParser) parseOr() (QueryExpr, error) {
+	var exprs QueryOr
+
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		// Flatten nested OR groups and skip groups with only an order term.
+		switch expr := expr.(type) {
+		case nil:
+		case QueryOr:
+			exprs = append(exprs, expr...)
+		default:
+			exprs = append(exprs, expr)
+		}
+
+		if t := p.peek(); t == nil || !t.isKeyword("OR") {
+			break
+		}
+
+		p.pos++
+	}
+
+	switch len(exprs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return exprs[0], nil
+	default:
+		return exprs, nil
+	}
+}
+
+// parseAnd parses consecutive terms until the end of the group or an OR. It
+// returns a nil expression if the group only has an order term.
+func (p *queryParser) parseAnd() (QueryExpr, error) {
+	var exprs QueryAnd
+	var poster bool
+	var order bool
+
+	for {
+		t := p.peek()
+		if t == nil || t.kind == queryClose || t.isKeyword("OR") {
+			break
+		}
+
+		// The AND keyword is optional.
+		if t.isKeyword("AND") {
+			p.pos++
+			continue
+		}
+
+		// Order terms aren't filters, so they're not part of the tree.
+		if value, ok := t.orderValue(); ok {
+			if p.order != nil {
+				return nil, ErrQueryAlreadyHasOrder
+			}
+
+			o, err := ParseOrder(value)
+			if err != nil {
+				return nil, err
+			}
+
+			p.order = &o
+			p.pos++
+			order = true
+			continue
+		}
+
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		switch expr := expr.(type) {
+		case nil:
+			// Group with only an order term.
+			continue
+		case QueryAnd:
+			exprs = append(exprs, expr...)
+			continue
+		case QueryPoster:
+			// Disallow groups with multiple users and error out.
+			if poster {
+				return nil, ErrQueryAlreadyHasUser
+			}
+			poster = true
+		}
+
+		exprs = append(exprs, expr)
+	}
+
+	switch len(exprs) {
+	case 0:
+		if order {
+			return nil, nil
+		}
+		return nil, ErrQueryEmptyExpr
+	case 1:
+		return exprs[0], nil
+	default:
+		return exprs, nil
+	}
+}
+
+// parseUnary parses a single term, a negation or a group.
+func (p *queryParser) parseUnary() (QueryExpr, error) {
+	t := p.next()
+	if t == nil {
+		return nil, ErrQueryEmptyExpr
+	}
+
+	switch {
+	case t.kind == queryMinus, t.isKeyword("NOT"):
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		// Cancel out double negations.
+		switch expr := expr.(type) {
+		case nil:
+			// Negating only an order term.
+			return nil, ErrQueryEmptyExpr
+		case QueryNot:
+			return expr.Expr, nil
+		}
+
+		return QueryNot{expr}, nil
+
+	case t.kind == queryOpen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if t := p.next(); t == nil || t.kind != queryClose {
+			return nil, ErrQueryUnbalanced
+		}
+
+		return expr, nil
+
+	case t.kind == queryClose:
+		return nil, ErrQueryUnbalanced
+	}
+
+	if _, ok := t.orderValue(); ok {
+		return nil, ErrQueryNegatedOrder
+	}
+
+	// Exit if there are too many terms.
+	if p.terms++; p.terms > QueryTagLimit {
+		return nil, ErrQueryHasTooMayTags
+	}
+
+	if !t.quoted && strings.HasPrefix(t.word, "@") {
+		var user = strings.TrimPrefix(t.word, "@")
+		if user == "" {
+			return nil, ErrIllegalName
+		}
+
+		return QueryPoster(user), nil
+	}
+
+	if !t.quoted || t.quotedValue() {
+		if expr, ok, err := parseQualifier(t.word); ok {
+			return expr, err
+		}
+	}
+
+	if !t.quoted {
+		if ns, name := SplitTagNamespace(t.word); ns != "" && name == "*" {
+			return QueryNamespace(ns), nil
+		}
+	}
+
+	// Make sure the tag is legal before adding. Quoted tags may be older tags
+	// with names that are reserved now.
+	var valid = TagIsValid
+	if t.quoted {
+		valid = TagNameIsValid
+	}
+
+	if err := valid(t.word); err != nil {
+		return nil, err
+	}
+
+	return QueryTag(t.word), nil
+}
+
+var (
+	wordEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	// queryKeywords contains words that must be quoted to be used as tags.
+	queryKeywords = []string{"OR", "AND", "NOT"}
+)
+
+// EscapeTag escapes a tag.
+func EscapeTag(name string) string {
+	if tagNeedsQuotes(name) {
+		return "'" + wordEscaper.Replace(name) + "'"
+	}
+	return name
+}
+
+// tagNeedsQuotes returns true if the tag would not be parsed back as itself
+// without quotes.
+func tagNeedsQuotes(name string) bool {
+	if name == "" || strings.IndexFunc(name, unicode.IsSpace) > -1 {
+		return true
+	}
+
+	// Older tags with names that are reserved now only parse when quoted.
+	if TagIsValid(name) != nil {
+		return true
+	}
+
+	if strings.ContainsAny(name, `'"\`) || strings.ContainsAny(name[:1], "(-@") {
+		return true
+	}
+
+	if key, _, ok := splitQualifier(name); ok && queryQualifiers[key] != nil {
+		return true
+	}
+
+	if strings.HasPrefix(name, orderPrefix) {
+		return true
+	}
+
+	for _, keyword := range queryKeywords {
+		if name == keyword {
+			return true
+		}
+	}
+
+	// Check for a closing parenthesis that would end the word early.
+	var parens int
+	for _, r := range name {
+		switch r {
+		case '(':
+			parens++
+		case ')':
+			if parens == 0 {
+				return true
+			}
+			parens--
+		}
+	}
+
+	return false
+}
+
+// queryQualifiers maps the keys of qualifier terms to their parsers.
+var queryQualifiers = map[string]func(key, value string) (QueryExpr, error){
+	"type":   parseQueryType,
+	"mime":   parseQueryMIME,
+	"size":   parseQueryCompare,
+	"width":  parseQueryCompare,
+	"height": parseQueryCompare,
+	"score":  parseQueryCompare,
+	"ratio":  parseQueryCompare,
+	"after":  parseQueryDate,
+	"before": parseQueryDate,
+	"text":   parseQueryText,
+	"fav":    parseQueryFavorite,
+	"pool":   parseQueryPool,
+	"parent": parseQueryParent,
+	"hash":   parseQueryHash,
+}
+
+// splitQualifier splits the word into the qualifier's key and value.
+func splitQualifier(word string) (key, value string, ok bool) {
+	parts := strings.SplitN(word, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// parseQualifier parses the word as a qualifier term. It returns false if the
+// word is not a qualifier, in which case it should be treated as a tag.
+func parseQualifier(word string) (QueryExpr, bool, error) {
+	key, value, ok := splitQualifier(word)
+	if !ok {
+		return nil, false, nil
+	}
+
+	parse, ok := queryQualifiers[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	expr, err := parse(key, value)
+	return expr, true, err
+}
+
+func parseQueryType(key, value string) (QueryExpr, error) {
+	if !isMIMEPart(value) {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+	return QueryType(value), nil
+}
+
+func parseQueryMIME(key, value string) (QueryExpr, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || !isMIMEPart(parts[0]) || !isMIMEPart(parts[1]) {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+	return QueryMIME(value), nil
+}
+
+// isMIMEPart returns true if the string is a valid MIME type or subtype. The
+// check is stricter than the specification.
+func isMIMEPart(part string) bool {
+	if part == "" {
+		return false
+	}
+
+	illi := strings.IndexFunc(part, func(r rune) bool {
+		return !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))) &&
+			!strings.ContainsRune("-+.", r)
+	})
+
+	return illi == -1
+}
+
+func parseQueryText(key, value string) (QueryExpr, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+	return QueryText(value), nil
+}
+
+func parseQueryFavorite(key, value string) (QueryExpr, error) {
+	if NameIsLegal(value) != nil {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+	return QueryFavorite(value), nil
+}
+
+func parseQueryPool(key, value string) (QueryExpr, error) {
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || i <= 0 {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+	return QueryPool(i), nil
+}
+
+func parseQueryParent(key, value string) (QueryExpr, error) {
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || i <= 0 {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+	return QueryParent(i), nil
+}
+
+func parseQueryHash(key, value string) (QueryExpr, error) {
+	// Hashes are stored in lowercase.
+	var hash = strings.ToLower(value)
+
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+
+	return QueryHash(hash), nil
+}
+
+func parseQueryCompare(key, value string) (QueryExpr, error) {
+	var cmp = QueryCompare{
+		Field: QueryField(key),
+		Op:    OpEqual,
+	}
+
+	var str = value
+
+	for _, op := range queryOps {
+		if strings.HasPrefix(str, string(op)) {
+			cmp.Op = op
+			str = strings.TrimPrefix(str, string(op))
+			break
+		}
+	}
+
+	var err error
+
+	switch cmp.Field {
+	case QueryFieldSize:
+		var size datasize.ByteSize
+		err = size.UnmarshalText([]byte(str))
+		cmp.Value = float64(size)
+
+	case QueryFieldRatio:
+		// Accept both "16:9" and "1.77".
+		if parts := strings.Split(str, ":"); len(parts) == 2 {
+			var w, h int
+			if w, err = strconv.Atoi(parts[0]); err == nil {
+				if h, err = strconv.Atoi(parts[1]); err == nil && h == 0 {
+					err = ErrQueryInvalidValue{key, value}
+				}
+			}
+			if err == nil {
+				cmp.Value = float64(w) / float64(h)
+			}
+		} else {
+			cmp.Value, err = strconv.ParseFloat(str, 64)
+		}
+
+	default:
+		var i int
+		i, err = strconv.Atoi(str)
+		cmp.Value = float64(i)
+	}
+
+	if err != nil || (cmp.Value < 0 && cmp.Field != QueryFieldScore) {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+
+	return cmp, nil
+}
+
+const queryDateLayout = "2006-01-02"
+
+func parseQueryDate(key, value string) (QueryExpr, error) {
+	t, err := time.Parse(queryDateLayout, value)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, value)
+	}
+	if err != nil {
+		return nil, ErrQueryInvalidValue{key, value}
+	}
+
+	return QueryDate{Before: key == "before", Time: t}, nil
+}
+
+// OrderBy is the key that posts are sorted by.
+type OrderBy string
+
+const (
+	// OrderNewest sorts the latest posts first. It is the default.
+	OrderNewest OrderBy = "newest"
+	// OrderOldest sorts the earliest posts first.
+	OrderOldest OrderBy = "oldest"
+	// OrderLargest sorts the largest files first.
+	OrderLargest OrderBy = "largest"
+	// OrderSmallest sorts the smallest files first.
+	OrderSmallest OrderBy = "smallest"
+	// OrderRandom shuffles posts using the order's seed. The same seed will
+	// always give the same order, so pagination stays consistent. A random
+	// seed is used if none is given.
+	OrderRandom OrderBy = "random"
+	// OrderMostTagged sorts posts with the most tags first.
+	OrderMostTagged OrderBy = "most-tagged"
+	// OrderScore sorts posts with the highest score first.
+	OrderScore OrderBy = "score"
+)
+
+// AllOrders returns all possible orders, starting with the default one.
+func AllOrders() []OrderBy {
+	return []OrderBy{
+		OrderNewest, OrderOldest, OrderLargest, OrderSmallest, OrderRandom, OrderMostTagged,
+		OrderScore,
+	}
+}
+
+// orderPrefix is the prefix of order terms in search queries.
+const orderPrefix = "order:"
+
+// ErrInvalidOrder is returned when the order is unknown.
+var ErrInvalidOrder = httperr.New(400, "invalid order")
+
+// Order is the order of searched posts. A zero-value Order sorts the latest
+// posts first.
+type Order struct {
+	By OrderBy
+	// Seed is the seed for OrderRandom. It is ignored for other orders.
+	Seed int64
+}
+
+// ParseOrder parses the order in the form of "key" or "random:seed". An empty
+// string returns a zero-value Order.
+func ParseOrder(s string) (Order, error) {
+	parts := strings.SplitN(s, ":", 2)
+
+	var order = Order{By: OrderBy(parts[0])}
+
+	// Only random orders can have a seed.
+	if len(parts) == 2 && order.By != OrderRandom {
+		return Order{}, ErrInvalidOrder
+	}
+
+	switch order.By {
+	case "", OrderNewest:
+		return Order{}, nil
+	case OrderOldest, OrderLargest, OrderSmallest, OrderMostTagged, OrderScore:
+		return order, nil
+	case OrderRandom:
+		if len(parts) == 2 {
+			i, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return Order{}, ErrInvalidOrder
+			}
+			order.Seed = i
+		}
+		return order, nil
+	default:
+		return Order{}, ErrInvalidOrder
+	}
+}
+
+// IsZero returns true if the order is the default order.
+func (o Order) IsZero() bool {
+	return o.By == "" || o.By == OrderNewest
+}
+
+// String encodes the order back to the form accepted by ParseOrder.
+func (o Order) String() string {
+	if o.IsZero() {
+		return string(OrderNewest)
+	}
+
+	if o.By == OrderRandom && o.Seed != 0 {
+		return fmt.Sprintf("%s:%d", o.By, o.Seed)
+	}
+
+	return string(o.By)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -11,7 +12,6 @@ import (
 
 	"github.com/bwmarrin/snowflake"
 	"github.com/diamondburned/smolboard/server/httperr"
-	"github.com/mattn/go-shellwords"
 )
 
 const ms = int64(time.Millisecond)
@@ -48,6 +48,8 @@ func NameIsLegal(name string) error {
 // error.
 type ErrResponse struct {
 	Error string `json:"error"`
+	// PostID is the ID of the existing post if the error is ErrDuplicatePost.
+	PostID int64 `json:"post_id,omitempty"`
 }
 
 type Permission int8
@@ -209,14 +211,131 @@ type Post struct {
 	ContentType string        `json:"content_type" db:"contenttype"`
 	Permission  Permission    `json:"permission"   db:"permission"`
 	Attributes  PostAttribute `json:"attributes"   db:"attributes"`
+	// Title, Description and Source are set by the poster. They are empty if
+	// not set.
+	Title       string `json:"title"       db:"title"`
+	Description string `json:"description" db:"description"`
+	Source      string `json:"source"      db:"source"`
+	// Score is the number of upvotes minus the number of downvotes.
+	Score int `json:"score" db:"score"`
+	// ParentID is the ID of the post that this post is a variant of, or nil if
+	// the post has no parent.
+	ParentID *int64 `json:"parent_id" db:"parent"`
+	// Hash is the hexadecimal SHA-256 hash of the post's content. It is empty
+	// for posts uploaded before hashes were stored.
+	Hash string `json:"hash" db:"hash"`
+	// PHash is the perceptual hash of the post's image or the first frame of
+	// its video. It is nil if the hash couldn't be computed.
+	PHash *int64 `json:"-" db:"phash"`
 }
 
+const (
+	// DefaultSimilarDistance is the default maximum distance between the
+	// perceptual hashes of similar posts.
+	DefaultSimilarDistance = 10
+	// NearDuplicateDistance is the maximum distance between the perceptual
+	// hashes of posts that look almost the same.
+	NearDuplicateDistance = 4
+)
+
+// ErrInvalidDistance is returned when the maximum distance between perceptual
+// hashes is out of range.
+var ErrInvalidDistance = httperr.New(400, "distance must be between 0 and 64")
+
+// SimilarPost is a post found by its perceptual hash.
+type SimilarPost struct {
+	Post
+	// Distance is the number of bits that differ between the perceptual hashes,
+	// from 0 to 64. Lower is more similar.
+	Distance int `json:"distance"`
+}
+
+// UploadedPost is a post that was just uploaded.
+type UploadedPost struct {
+	Post
+	// NearDuplicates contains the IDs of visible posts that look almost the
+	// same as this post.
+	NearDuplicates []int64 `json:"near_duplicates,omitempty"`
+}
+
+// ErrDuplicatePost is returned when the uploaded content is the same as an
+// existing post's.
+type ErrDuplicatePost struct {
+	// PostID is the ID of the existing post. It is zero if the user can't see
+	// the post.
+	PostID int64
+}
+
+func (err ErrDuplicatePost) StatusCode() int {
+	return 409
+}
+
+func (err ErrDuplicatePost) Error() string {
+	if err.PostID == 0 {
+		return "post already exists"
+	}
+	return fmt.Sprintf("post already exists as %d", err.PostID)
+}
+
+const (
+	// MaxPostTitleLen is the maximum length of a post title in bytes.
+	MaxPostTitleLen = 256
+	// MaxPostDescriptionLen is the maximum length of a post description in
+	// bytes.
+	MaxPostDescriptionLen = 8192
+	// MaxPostSourceLen is the maximum length of a post source URL in bytes.
+	MaxPostSourceLen = 2048
+)
+
 var (
 	ErrMissingExt     = httperr.New(400, "file does not have extension")
 	ErrPostNotFound   = httperr.New(404, "post not found")
+	ErrParentCycle    = httperr.New(400, "post cannot be its own ancestor")
 	ErrPageCountLimit = httperr.New(400, "count is over 100 limit")
+	ErrCursorHasBoth  = httperr.New(400, "cursor cannot be both before and after")
+
+	ErrPostTitleTooLong = httperr.New(400,
+		fmt.Sprintf("post title is too long (max %d)", MaxPostTitleLen))
+	ErrPostDescriptionTooLong = httperr.New(400,
+		fmt.Sprintf("post description is too long (max %d)", MaxPostDescriptionLen))
+	ErrIllegalSource = httperr.New(400,
+		fmt.Sprintf("post source must be an HTTP URL (max %d)", MaxPostSourceLen))
 )
 
+// PostEdit changes the fields of a post that are set by the poster. Nil fields
+// are left unchanged. A zero Parent removes the post's parent.
+type PostEdit struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Source      *string `json:"source,omitempty"`
+	Parent      *int64  `json:"parent,omitempty"`
+}
+
+// PostEditIsValid returns nil if the edit is valid else an error. The source
+// must be empty or an HTTP URL.
+func PostEditIsValid(e PostEdit) error {
+	if e.Title != nil && len(*e.Title) > MaxPostTitleLen {
+		return ErrPostTitleTooLong
+	}
+
+	if e.Description != nil && len(*e.Description) > MaxPostDescriptionLen {
+		return ErrPostDescriptionTooLong
+	}
+
+	if e.Source != nil && *e.Source != "" {
+		if len(*e.Source) > MaxPostSourceLen {
+			return ErrIllegalSource
+		}
+
+		u, err := url.Parse(*e.Source)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrIllegalSource
+		}
+	}
+
+	return nil
+}
+
 // SetPoster sets the post's poster.
 func (p *Post) SetPoster(poster string) {
 	cpy := poster
@@ -258,6 +377,98 @@ type PostExtended struct {
 	PosterUser *UserPart `json:"poster_user"`
 	// Tags is manually queried externally.
 	Tags []PostTag `json:"tags"`
+	// TagGroups contains the same tags as Tags grouped by their categories,
+	// sorted by the categories' order. Tags without a category are in the last
+	// group, which has an empty category name.
+	TagGroups []TagGroup `json:"tag_groups"`
+	// Favorites is the number of users who favorited the post.
+	Favorites int `json:"favorites"`
+	// Favorited is true if the current user favorited the post.
+	Favorited bool `json:"favorited"`
+	// Vote is the current user's vote on the post.
+	Vote Vote `json:"vote"`
+	// Pools contains the pools that the post is in and the user can see.
+	Pools []Pool `json:"pools"`
+	// Parent is the post's parent. It is nil if the post has no parent or if
+	// the user cannot see it.
+	Parent *Post `json:"parent"`
+	// Children contains the first MaxPostChildren posts that the user can see
+	// with this post as their parent, oldest first. The rest can be searched
+	// with the parent term.
+	Children []Post `json:"children"`
+}
+
+// MaxPostChildren is the maximum number of children returned with a post.
+const MaxPostChildren = 50
+
+// Vote is a user's vote on a post.
+type Vote int8
+
+const (
+	VoteDown Vote = -1
+	VoteNone Vote = 0
+	VoteUp   Vote = 1
+)
+
+// ErrInvalidVote is returned when the vote is not -1, 0 or 1.
+var ErrInvalidVote = httperr.New(400, "invalid vote")
+
+// IsValid returns true if the vote is known.
+func (v Vote) IsValid() bool {
+	return v >= VoteDown && v <= VoteUp
+}
+
+// MaxBatchPosts is the maximum number of posts in a PostBatch.
+const MaxBatchPosts = 1000
+
+// PostBatch is an edit applied to multiple posts at once.
+type PostBatch struct {
+	PostIDs []int64 `json:"post_ids"`
+	// AddTags and RemoveTags are the tags to add to and remove from each post.
+	// Adding tags that the post already has and removing tags that the post
+	// doesn't have are not errors.
+	AddTags    []string `json:"add_tags,omitempty"`
+	RemoveTags []string `json:"remove_tags,omitempty"`
+	// Permission is the new permission of each post. The permission is not
+	// changed if it's nil.
+	Permission *Permission `json:"permission,omitempty"`
+}
+
+// PostBatchResult is the result of a PostBatch on a single post. Either all or
+// none of the changes are applied to the post.
+type PostBatchResult struct {
+	PostID int64 `json:"post_id"`
+	// Error is the reason the post failed to be changed. It is empty if the
+	// post was changed.
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	ErrBatchEmpty    = httperr.New(400, "batch has no posts")
+	ErrBatchTooLarge = httperr.New(400, fmt.Sprintf("batch has too many posts (max %d)", MaxBatchPosts))
+)
+
+// TagEditAction is the change made to a post's tags.
+type TagEditAction string
+
+const (
+	TagEditAdd    TagEditAction = "add"
+	TagEditRemove TagEditAction = "remove"
+)
+
+// TagEdit is a logged change to a post's tags.
+type TagEdit struct {
+	ID      int64         `db:"id"      json:"id"`
+	PostID  int64         `db:"postid"  json:"post_id"`
+	TagName string        `db:"tagname" json:"tag_name"`
+	Action  TagEditAction `db:"action"  json:"action"`
+	// Actor is nil if the user who made the change is deleted.
+	Actor *string `db:"actor" json:"actor"`
+}
+
+// CreatedTime returns the time the change was made.
+func (e TagEdit) CreatedTime() time.Time {
+	return time.Unix(0, snowflake.ID(e.ID).Time()*ms)
 }
 
 type PostTag struct {
@@ -273,6 +484,9 @@ var (
 	ErrIllegalTag      = httperr.New(400, "tag contains illegal character")
 	ErrTagAlreadyAdded = httperr.New(400, "tag is already added")
 	ErrTagTooLong      = httperr.New(400, fmt.Sprintf("tag is too long (max %d)", MaxTagLen))
+	ErrTagNotFound     = httperr.New(404, "tag not found")
+	ErrTagExists       = httperr.New(409, "tag already exists, merge the tags instead")
+	ErrTagMergeSelf    = httperr.New(400, "tag cannot be merged into itself")
 )
 
 // Escaped returns the escaped tag string.
@@ -280,11 +494,327 @@ func (t PostTag) Escaped() string {
 	return EscapeTag(t.TagName)
 }
 
+// Namespace returns the namespace of the tag, or an empty string if the tag
+// has none.
+func (t PostTag) Namespace() string {
+	ns, _ := SplitTagNamespace(t.TagName)
+	return ns
+}
+
+// Name returns the tag name without its namespace.
+func (t PostTag) Name() string {
+	_, name := SplitTagNamespace(t.TagName)
+	return name
+}
+
+// SplitTagNamespace splits the tag into its namespace and the name after it,
+// such as "artist" and "foo" for "artist:foo". The namespace is empty if the
+// tag has none, in which case the name is the whole tag. Namespaces may only
+// contain lowercase letters, digits and underscores.
+func SplitTagNamespace(tag string) (namespace, name string) {
+	parts := strings.SplitN(tag, ":", 2)
+	if len(parts) != 2 || !namespaceIsValid(parts[0]) {
+		return "", tag
+	}
+	return parts[0], parts[1]
+}
+
+func namespaceIsValid(ns string) bool {
+	if ns == "" {
+		return false
+	}
+
+	illi := strings.IndexFunc(ns, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_')
+	})
+
+	return illi == -1
+}
+
+// RestrictedTag is a tag that only users with at least the given permission
+// can add to or remove from posts.
+type RestrictedTag struct {
+	TagName    string     `db:"tagname"    json:"tag_name"`
+	Permission Permission `db:"permission" json:"permission"`
+}
+
+var (
+	ErrTagRestricted         = httperr.New(403, "tag is restricted to a higher permission")
+	ErrRestrictedTagNotFound = httperr.New(404, "tag is not restricted")
+)
+
+// TagCategory describes how tags under the namespace with the same name are
+// displayed.
+type TagCategory struct {
+	Name string `db:"name" json:"name"`
+	// Color is the display color in the "#rrggbb" format.
+	Color string `db:"color" json:"color"`
+	// SortOrder is the position of the category; lower comes first.
+	SortOrder int `db:"sortorder" json:"sort_order"`
+}
+
+// TagGroup contains the tags of a post under the same category.
+type TagGroup struct {
+	Category TagCategory `json:"category"`
+	Tags     []PostTag   `json:"tags"`
+}
+
+var (
+	ErrTagCategoryNotFound = httperr.New(404, "tag category not found")
+	ErrIllegalTagCategory  = httperr.New(400, "tag category name is illegal or reserved")
+	ErrIllegalColor        = httperr.New(400, "color must be in the #rrggbb format")
+)
+
+// TagCategoryIsValid returns nil if the category is valid else an error. The
+// category name must be a valid namespace that isn't a search qualifier, and
+// the color must be in the "#rrggbb" format.
+func TagCategoryIsValid(c TagCategory) error {
+	if !namespaceIsValid(c.Name) || c.Name+":" == orderPrefix {
+		return ErrIllegalTagCategory
+	}
+	if _, ok := queryQualifiers[c.Name]; ok {
+		return ErrIllegalTagCategory
+	}
+
+	if len(c.Color) != 7 || c.Color[0] != '#' {
+		return ErrIllegalColor
+	}
+	if _, err := strconv.ParseUint(c.Color[1:], 16, 32); err != nil {
+		return ErrIllegalColor
+	}
+
+	return nil
+}
+
+// TagAlias maps an alias to its canonical tag. Posts tagged with the alias are
+// tagged with the canonical tag instead, and searching for the alias searches
+// for the canonical tag.
+type TagAlias struct {
+	Alias   string `db:"alias"   json:"alias"`
+	TagName string `db:"tagname" json:"tag_name"`
+}
+
+var (
+	ErrTagAliasNotFound = httperr.New(404, "tag alias not found")
+	ErrTagAliasExists   = httperr.New(409, "tag alias already exists")
+	ErrTagAliasSelf     = httperr.New(400, "tag cannot be an alias of itself")
+	ErrTagAliasChained  = httperr.New(400, "tag with aliases cannot be an alias")
+)
+
+// TagImplication makes posts tagged with the tag also tagged with the implied
+// tag. Implications are transitive, so a tag also implies the tags implied by
+// its implied tags.
+type TagImplication struct {
+	TagName string `db:"tagname" json:"tag_name"`
+	Implied string `db:"implied" json:"implied"`
+}
+
+var (
+	ErrTagImplicationNotFound = httperr.New(404, "tag implication not found")
+	ErrTagImplicationExists   = httperr.New(409, "tag implication already exists")
+	ErrTagImplicationCycle    = httperr.New(400, "tag implication would create a cycle")
+)
+
+// MaxBlacklistLen is the maximum number of tags in a user's blacklist.
+const MaxBlacklistLen = 256
+
+var (
+	ErrPostBlacklisted       = httperr.New(403, "post has blacklisted tags")
+	ErrTagAlreadyBlacklisted = httperr.New(409, "tag is already blacklisted")
+	ErrTagNotBlacklisted     = httperr.New(404, "tag is not blacklisted")
+	ErrBlacklistFull         = httperr.New(400,
+		fmt.Sprintf("blacklist is full (max %d)", MaxBlacklistLen))
+)
+
+// MaxCommentLen is the maximum length of a comment in bytes.
+const MaxCommentLen = 4096
+
+// Comment is a user's comment on a post.
+type Comment struct {
+	ID     int64 `db:"id"     json:"id"`
+	PostID int64 `db:"postid" json:"post_id"`
+	// Commenter is nil if the commenter is deleted.
+	Commenter *string `db:"commenter" json:"commenter"`
+	Body      string  `db:"body"      json:"body"`
+}
+
+// CreatedTime returns the time the comment was posted.
+func (c Comment) CreatedTime() time.Time {
+	return time.Unix(0, snowflake.ID(c.ID).Time()*ms)
+}
+
+// CommentList is a page of a post's comments, oldest first.
+type CommentList struct {
+	Comments []Comment `json:"comments"`
+	Total    int       `json:"total"`
+}
+
+// NoComments is a zero-value comment list containing no comments.
+var NoComments = CommentList{}
+
+var (
+	ErrCommentNotFound = httperr.New(404, "comment not found")
+	ErrEmptyComment    = httperr.New(400, "empty comment not allowed")
+	ErrCommentTooLong  = httperr.New(400,
+		fmt.Sprintf("comment is too long (max %d)", MaxCommentLen))
+)
+
+// CommentIsValid returns nil if the comment body is valid else an error.
+func CommentIsValid(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return ErrEmptyComment
+	}
+
+	if len(body) > MaxCommentLen {
+		return ErrCommentTooLong
+	}
+
+	return nil
+}
+
+const (
+	// MaxPoolNameLen is the maximum length of a pool name in bytes.
+	MaxPoolNameLen = 256
+	// MaxPoolDescriptionLen is the maximum length of a pool description in
+	// bytes.
+	MaxPoolDescriptionLen = 8192
+	// MaxPoolPosts is the maximum number of posts in a pool.
+	MaxPoolPosts = 1000
+)
+
+// Pool is an ordered collection of posts, such as the pages of a comic. Like
+// posts, the pool is only visible to its owner and users with at least its
+// permission.
+type Pool struct {
+	ID          int64      `db:"id"          json:"id"`
+	Name        string     `db:"name"        json:"name"`
+	Description string     `db:"description" json:"description"`
+	Permission  Permission `db:"permission"  json:"permission"`
+	// Owner is nil if the owner is deleted.
+	Owner *string `db:"owner" json:"owner"`
+}
+
+// CreatedTime returns the time the pool was created.
+func (p Pool) CreatedTime() time.Time {
+	return time.Unix(0, snowflake.ID(p.ID).Time()*ms)
+}
+
+// PoolExtended is a pool with its posts in order. Posts that the user cannot
+// see are left out.
+type PoolExtended struct {
+	Pool
+	Posts []Post `json:"posts"`
+}
+
+// Index returns the index of the post in the pool, or -1 if the post isn't in
+// it.
+func (p PoolExtended) Index(postID int64) int {
+	for i, post := range p.Posts {
+		if post.ID == postID {
+			return i
+		}
+	}
+	return -1
+}
+
+// PoolList is a page of pools, newest first.
+type PoolList struct {
+	Pools []Pool `json:"pools"`
+	Total int    `json:"total"`
+}
+
+// NoPools is a zero-value pool list containing no pools.
+var NoPools = PoolList{}
+
+// PoolEdit changes a pool. Nil fields are left unchanged.
+type PoolEdit struct {
+	Name        *string     `json:"name,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	Permission  *Permission `json:"permission,omitempty"`
+}
+
+var (
+	ErrPoolNotFound      = httperr.New(404, "pool not found")
+	ErrPostNotInPool     = httperr.New(404, "post is not in the pool")
+	ErrPostAlreadyInPool = httperr.New(409, "post is already in the pool")
+	ErrPoolOrderMismatch = httperr.New(400, "order must have all of the pool's posts")
+	ErrIllegalPoolName   = httperr.New(400,
+		fmt.Sprintf("pool name must not be empty nor too long (max %d)", MaxPoolNameLen))
+	ErrPoolDescriptionTooLong = httperr.New(400,
+		fmt.Sprintf("pool description is too long (max %d)", MaxPoolDescriptionLen))
+	ErrPoolFull = httperr.New(400,
+		fmt.Sprintf("pool is full (max %d)", MaxPoolPosts))
+)
+
+// PoolEditIsValid returns nil if the edit is valid else an error.
+func PoolEditIsValid(e PoolEdit) error {
+	if e.Name != nil && (strings.TrimSpace(*e.Name) == "" || len(*e.Name) > MaxPoolNameLen) {
+		return ErrIllegalPoolName
+	}
+
+	if e.Description != nil && len(*e.Description) > MaxPoolDescriptionLen {
+		return ErrPoolDescriptionTooLong
+	}
+
+	if e.Permission != nil && !e.Permission.IsValid() {
+		return ErrInvalidPermission
+	}
+
+	return nil
+}
+
+// MaxTagDescriptionLen is the maximum length of a tag description in bytes.
+const MaxTagDescriptionLen = 16384
+
+// TagRevision is a revision of a tag's description, which is written in
+// Markdown. The latest revision is the current description.
+type TagRevision struct {
+	ID          int64  `db:"id"          json:"id"`
+	TagName     string `db:"tagname"     json:"tag_name"`
+	Description string `db:"description" json:"description"`
+	// Editor is nil if the editor is deleted.
+	Editor *string `db:"editor" json:"editor"`
+}
+
+// CreatedTime returns the time the revision was created.
+func (r TagRevision) CreatedTime() time.Time {
+	return time.Unix(0, snowflake.ID(r.ID).Time()*ms)
+}
+
+var (
+	ErrTagDescriptionNotFound = httperr.New(404, "tag has no description")
+	ErrTagDescriptionTooLong  = httperr.New(400,
+		fmt.Sprintf("tag description is too long (max %d)", MaxTagDescriptionLen))
+)
+
 // TagIsValid returns nil if the tag is valid else an error. A tag is invalid if
-// it's empty, it's longer than 128 bytes, it's prefixed with an at sign "@" or
-// it contains anything not a graphical character defined by the Unicode
-// standards.
+// TagNameIsValid rejects it, it's prefixed with a minus sign "-", or it has a
+// namespace but no name or the name "*". New tags must be valid.
 func TagIsValid(tagName string) error {
+	if err := TagNameIsValid(tagName); err != nil {
+		return err
+	}
+
+	// The prefix is reserved for the search query syntax.
+	if strings.HasPrefix(tagName, "-") {
+		return ErrIllegalTag
+	}
+
+	// The wildcard is reserved for searching the whole namespace.
+	if ns, name := SplitTagNamespace(tagName); ns != "" && (name == "" || name == "*") {
+		return ErrIllegalTag
+	}
+
+	return nil
+}
+
+// TagNameIsValid returns nil if the tag name can refer to an existing tag else
+// an error. A tag name is invalid if it's empty, it's longer than 128 bytes,
+// it's prefixed with an at sign "@" or it contains anything not a graphical
+// character defined by the Unicode standards. Unlike TagIsValid, it accepts
+// tags made before their names were reserved, so they can still be searched
+// for and removed.
+func TagNameIsValid(tagName string) error {
 	if tagName == "" {
 		return ErrEmptyTag
 	}
@@ -305,24 +835,6 @@ func TagIsValid(tagName string) error {
 	return nil
 }
 
-// Query represents the parsed query string. A zero-value PostQuery searches
-// for nothing and thus will list all posts.
-type Query struct {
-	Poster string
-	Tags   []string
-}
-
-// QueryTagLimit is the maximum number of tags allowed in a single query.
-const QueryTagLimit = 1024
-
-var (
-	ErrQueryAlreadyHasUser = httperr.New(400, "search query already has a user filter")
-	ErrQueryHasTooMayTags  = httperr.New(400, "search query has too many tags")
-)
-
-// AllPosts searches for all posts; it is a zero value instance of PostQuery.
-var AllPosts = Query{}
-
 // SearchResults is the results returned from the queried posts.
 type SearchResults struct {
 	// Posts contains the paginated list of posts.
@@ -334,95 +846,48 @@ type SearchResults struct {
 	// User is the user stated in the search query. It is nil if there's no user
 	// stated.
 	User *UserPart `json:"user,omitempty"`
+	// NextCursor is the ID to page after for the next page. It is zero if
+	// there's no next page.
+	NextCursor int64 `json:"next_cursor,omitempty"`
+	// PrevCursor is the ID to page before for the previous page. It is zero if
+	// there's no previous page.
+	PrevCursor int64 `json:"prev_cursor,omitempty"`
+	// Seed is the seed of the random order if the posts are shuffled. One is
+	// picked if the query has none, so it has to be searched with to keep the
+	// same order across pages.
+	Seed int64 `json:"seed,omitempty"`
+	// Facets are the tags that appear most often on the posts found, excluding
+	// the tags searched for. The count of each tag is the number of posts found
+	// with it. They are only filled when requested for the first page.
+	Facets []PostTag `json:"facets,omitempty"`
+}
+
+// MaxRelatedTags is the maximum number of related tags, such as facets.
+const MaxRelatedTags = 20
+
+// MaxFacetPosts is the maximum number of the newest matching posts that related
+// tags are counted from.
+const MaxFacetPosts = 1000
+
+// Cursor is the position to paginate from in a list of searched posts. Paging
+// from a cursor is stable even if posts are uploaded in between pages. A
+// zero-value Cursor starts from the first post.
+type Cursor struct {
+	// Before is the ID of the post to get the posts listed before.
+	Before int64
+	// After is the ID of the post to get the posts listed after.
+	After int64
+}
+
+// IsZero returns true if the cursor starts from the first post.
+func (c Cursor) IsZero() bool {
+	return c.Before == 0 && c.After == 0
 }
 
 // NoResults contains no search results; it is a zero value instance of
 // PostQueryResults.
 var NoResults = SearchResults{}
 
-// ParsePostQuery parses a search string to query the post gallery. The syntax
-// is space-delimited optionally quoted tags with an optional prefix in front to
-// indicate a post author. A post author search may only appear once. Below is
-// an example:
-//
-//     tag1 "tag with space" 'more spaces' @diamondburned
-//
-func ParsePostQuery(q string) (Query, error) {
-	// Fast path.
-	if q == "" {
-		return AllPosts, nil
-	}
-
-	words, err := shellwords.Parse(q)
-	if err != nil {
-		return AllPosts, httperr.Wrap(err, 400, "Invalid query")
-	}
-
-	var tags = words[:0]
-	var targetUser = ""
-
-	for _, word := range words {
-		if strings.HasPrefix(word, "@") {
-			// Disallow query with multiple users and error out.
-			if targetUser != "" {
-				return AllPosts, ErrQueryAlreadyHasUser
-			}
-
-			// Only allow non-empty mentions; ignore all random ats.
-			if user := strings.TrimPrefix(word, "@"); user != "" {
-				targetUser = user
-			}
-		} else {
-			// Make sure the tag is legal before re-adding.
-			if err := TagIsValid(word); err != nil {
-				return AllPosts, err
-			}
-			tags = append(tags, word)
-
-			// Exit if there are too many tag filters.
-			if len(tags) > QueryTagLimit {
-				return AllPosts, ErrQueryHasTooMayTags
-			}
-		}
-	}
-
-	return Query{
-		Poster: targetUser,
-		Tags:   tags,
-	}, nil
-}
-
-var wordEscaper = strings.NewReplacer(`'`, `\'`)
-
-// EscapeTag escapes a tag.
-func EscapeTag(name string) string {
-	if strings.IndexFunc(name, unicode.IsSpace) > -1 {
-		return fmt.Sprintf("'%s'", wordEscaper.Replace(name))
-	}
-	return name
-}
-
-// String encodes the parsed PostQuery to a regular string query.
-func (q Query) String() string {
-	var b strings.Builder
-
-	if q.Poster != "" {
-		b.WriteByte('@')
-		b.WriteString(q.Poster)
-		b.WriteByte(' ')
-	}
-
-	for i, tag := range q.Tags {
-		if i != 0 {
-			b.WriteByte(' ')
-		}
-
-		b.WriteString(EscapeTag(tag))
-	}
-
-	return b.String()
-}
-
 type Session struct {
 	ID       int64  `json:"id"       db:"id"`
 	Username string `json:"username" db:"username"`
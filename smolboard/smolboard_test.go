@@ -0,0 +1,307 @@
+package smolboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestParsePostQuery(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out Query
+		str string
+	}{{
+		in: `tag1 "tag with space" @diamondburned`,
+		out: Query{
+			Expr: QueryAnd{
+				QueryTag("tag1"),
+				QueryTag("tag with space"),
+				QueryPoster("diamondburned"),
+			},
+			Poster: "diamondburned",
+		},
+		str: `tag1 'tag with space' @diamondburned`,
+	}, {
+		in: `cat -nsfw -'screen shot'`,
+		out: Query{
+			Expr: QueryAnd{
+				QueryTag("cat"),
+				QueryNot{QueryTag("nsfw")},
+				QueryNot{QueryTag("screen shot")},
+			},
+		},
+		str: `cat -nsfw -'screen shot'`,
+	}, {
+		in:  `-nsfw`,
+		out: Query{Expr: QueryNot{QueryTag("nsfw")}},
+		str: `-nsfw`,
+	}, {
+		in: `(cat OR dog) outdoor`,
+		out: Query{
+			Expr: QueryAnd{
+				QueryOr{QueryTag("cat"), QueryTag("dog")},
+				QueryTag("outdoor"),
+			},
+		},
+		str: `(cat OR dog) outdoor`,
+	}, {
+		in: `cat dog OR (bird OR @someone) AND NOT (a b)`,
+		out: Query{
+			Expr: QueryOr{
+				QueryAnd{QueryTag("cat"), QueryTag("dog")},
+				QueryAnd{
+					QueryOr{QueryTag("bird"), QueryPoster("someone")},
+					QueryNot{QueryAnd{QueryTag("a"), QueryTag("b")}},
+				},
+			},
+		},
+		str: `cat dog OR (bird OR @someone) -(a b)`,
+	}, {
+		in:  `saber_(fate) 'OR' --x`,
+		out: Query{Expr: QueryAnd{QueryTag("saber_(fate)"), QueryTag("OR"), QueryTag("x")}},
+		str: `saber_(fate) 'OR' x`,
+	}, {
+		in:  `(saber_(fate))`,
+		out: Query{Expr: QueryTag("saber_(fate)")},
+		str: `saber_(fate)`,
+	}, {
+		in: `type:video size:>10MB width:>=1920 ratio:16:9 re:zero`,
+		out: Query{
+			Expr: QueryAnd{
+				QueryType("video"),
+				QueryCompare{QueryFieldSize, OpGreater, 10 * 1024 * 1024},
+				QueryCompare{QueryFieldWidth, OpGreaterEqual, 1920},
+				QueryCompare{QueryFieldRatio, OpEqual, 16.0 / 9.0},
+				QueryTag("re:zero"),
+			},
+		},
+		str: `type:video size:>10MB width:>=1920 ratio:1.7777777777777777 re:zero`,
+	}, {
+		in: `mime:image/png OR height:<=100 after:2020-01-01 -before:2020-06-01T12:00:00Z`,
+		out: Query{
+			Expr: QueryOr{
+				QueryMIME("image/png"),
+				QueryAnd{
+					QueryCompare{QueryFieldHeight, OpLessEqual, 100},
+					QueryDate{Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+					QueryNot{QueryDate{
+						Before: true,
+						Time:   time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+					}},
+				},
+			},
+		},
+		str: `mime:image/png OR height:<=100 after:2020-01-01 -before:2020-06-01T12:00:00Z`,
+	}, {
+		in: `artist:* -character:* artist:foo Re:*`,
+		out: Query{
+			Expr: QueryAnd{
+				QueryNamespace("artist"),
+				QueryNot{QueryNamespace("character")},
+				QueryTag("artist:foo"),
+				QueryTag("Re:*"),
+			},
+		},
+		str: `artist:* -character:* artist:foo Re:*`,
+	}, {
+		in: `text:sunset text:'beach "day"' -'text:x'`,
+		out: Query{
+			Expr: QueryAnd{
+				QueryText("sunset"),
+				QueryText(`beach "day"`),
+				QueryNot{QueryTag("text:x")},
+			},
+		},
+		str: `text:sunset text:'beach "day"' -'text:x'`,
+	}, {
+		in: `fav:diamondburned -fav:someone`,
+		out: Query{
+			Expr: QueryAnd{
+				QueryFavorite("diamondburned"),
+				QueryNot{QueryFavorite("someone")},
+			},
+		},
+		str: `fav:diamondburned -fav:someone`,
+	}, {
+		in:  `pool:42 cat`,
+		out: Query{Expr: QueryAnd{QueryPool(42), QueryTag("cat")}},
+		str: `pool:42 cat`,
+	}, {
+		in:  `hash:E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855`,
+		out: Query{Expr: QueryHash("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")},
+		str: `hash:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855`,
+	}, {
+		in:  `parent:7 -parent:8`,
+		out: Query{Expr: QueryAnd{QueryParent(7), QueryNot{QueryParent(8)}}},
+		str: `parent:7 -parent:8`,
+	}, {
+		in: `score:>=-5 score:10 order:score`,
+		out: Query{
+			Expr: QueryAnd{
+				QueryCompare{QueryFieldScore, OpGreaterEqual, -5},
+				QueryCompare{QueryFieldScore, OpEqual, 10},
+			},
+			Order: Order{By: OrderScore},
+		},
+		str: `score:>=-5 score:10 order:score`,
+	}, {
+		in:  `'size:big'`,
+		out: Query{Expr: QueryTag("size:big")},
+		str: `'size:big'`,
+	}, {
+		in: `cat OR (dog order:random:42)`,
+		out: Query{
+			Expr:  QueryOr{QueryTag("cat"), QueryTag("dog")},
+			Order: Order{By: OrderRandom, Seed: 42},
+		},
+		str: `cat OR dog order:random:42`,
+	}, {
+		in:  `order:most-tagged 'order:x'`,
+		out: Query{Expr: QueryTag("order:x"), Order: Order{By: OrderMostTagged}},
+		str: `'order:x' order:most-tagged`,
+	}, {
+		in:  `order:oldest`,
+		out: Query{Order: Order{By: OrderOldest}},
+		str: `order:oldest`,
+	}, {
+		in:  `order:newest`,
+		out: AllPosts,
+		str: ``,
+	}, {
+		in:  `  `,
+		out: AllPosts,
+		str: ``,
+	}}
+
+	for _, test := range tests {
+		q, err := ParsePostQuery(test.in)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", test.in, err)
+		}
+
+		if eq := deep.Equal(q, test.out); eq != nil {
+			t.Fatalf("Unexpected query parsed from %q: %v", test.in, eq)
+		}
+
+		if str := q.String(); str != test.str {
+			t.Fatalf("Unexpected query string from %q: %q", test.in, str)
+		}
+
+		// Ensure that the encoded string round-trips.
+		r, err := ParsePostQuery(q.String())
+		if err != nil {
+			t.Fatalf("Failed to re-parse %q: %v", q.String(), err)
+		}
+
+		if eq := deep.Equal(r, q); eq != nil {
+			t.Fatalf("Query %q does not round-trip: %v", q.String(), eq)
+		}
+	}
+}
+
+func TestParsePostQueryInvalid(t *testing.T) {
+	var tests = map[string]error{
+		"-":                                ErrIllegalTag,
+		"@a @b":                            ErrQueryAlreadyHasUser,
+		"(a @b) @c @d":                     ErrQueryAlreadyHasUser,
+		"(cat OR dog":                      ErrQueryUnbalanced,
+		"cat OR dog)":                      ErrQueryUnbalanced,
+		"cat OR":                           ErrQueryEmptyExpr,
+		"()":                               ErrQueryEmptyExpr,
+		`"unclosed quote`:                  ErrQueryUnclosedQuote,
+		"@":                                ErrIllegalName,
+		`a "@b" c`:                         ErrIllegalTag,
+		"cat -(dog OR -)":                  ErrQueryUnbalanced,
+		"cat AND NOT":                      ErrQueryEmptyExpr,
+		"cat (dog OR bird":                 ErrQueryUnbalanced,
+		"size:big":                         ErrQueryInvalidValue{"size", "big"},
+		"width:>-1":                        ErrQueryInvalidValue{"width", ">-1"},
+		"ratio:16:0":                       ErrQueryInvalidValue{"ratio", "16:0"},
+		"type:*":                           ErrQueryInvalidValue{"type", "*"},
+		"mime:image":                       ErrQueryInvalidValue{"mime", "image"},
+		"after:yesterday":                  ErrQueryInvalidValue{"after", "yesterday"},
+		"text:''":                          ErrQueryInvalidValue{"text", ""},
+		"size:'big'":                       ErrQueryInvalidValue{"size", "big"},
+		"score:1.5":                        ErrQueryInvalidValue{"score", "1.5"},
+		"pool:0":                           ErrQueryInvalidValue{"pool", "0"},
+		"pool:x":                           ErrQueryInvalidValue{"pool", "x"},
+		"parent:-1":                        ErrQueryInvalidValue{"parent", "-1"},
+		"hash:e3b0c442":                    ErrQueryInvalidValue{"hash", "e3b0c442"},
+		"fav:":                             ErrQueryInvalidValue{"fav", ""},
+		"fav:@a":                           ErrQueryInvalidValue{"fav", "@a"},
+		"artist:":                          ErrIllegalTag,
+		"order:whatever":                   ErrInvalidOrder,
+		"order:oldest:1":                   ErrInvalidOrder,
+		"order:newest:1":                   ErrInvalidOrder,
+		"order:random:x":                   ErrInvalidOrder,
+		"a order:oldest (b order:largest)": ErrQueryAlreadyHasOrder,
+		"-order:oldest":                    ErrQueryNegatedOrder,
+		"-(order:oldest)":                  ErrQueryEmptyExpr,
+	}
+
+	for query, expect := range tests {
+		if _, err := ParsePostQuery(query); err != expect {
+			t.Errorf("Unexpected error parsing %q: %v", query, err)
+		}
+	}
+}
+
+func TestSplitTagNamespace(t *testing.T) {
+	var tests = []struct {
+		tag, ns, name string
+	}{
+		{"artist:foo", "artist", "foo"},
+		{"source:http://example.com", "source", "http://example.com"},
+		{"character:", "character", ""},
+		{":o", "", ":o"},
+		{"Re:zero", "", "Re:zero"},
+		{"cat", "", "cat"},
+	}
+
+	for _, test := range tests {
+		ns, name := SplitTagNamespace(test.tag)
+		if ns != test.ns || name != test.name {
+			t.Errorf("Unexpected split of %q: %q, %q", test.tag, ns, name)
+		}
+	}
+}
+
+func TestTagCategoryIsValid(t *testing.T) {
+	var tests = map[TagCategory]error{
+		{Name: "artist", Color: "#ff0000"}:      nil,
+		{Name: "copy_right2", Color: "#AbCdEf"}: nil,
+		{Name: "Artist", Color: "#ff0000"}:      ErrIllegalTagCategory,
+		{Name: "size", Color: "#ff0000"}:        ErrIllegalTagCategory,
+		{Name: "order", Color: "#ff0000"}:       ErrIllegalTagCategory,
+		{Name: "artist", Color: "red"}:          ErrIllegalColor,
+		{Name: "artist", Color: "#ff00zz"}:      ErrIllegalColor,
+	}
+
+	for c, expect := range tests {
+		if err := TagCategoryIsValid(c); err != expect {
+			t.Errorf("Unexpected error validating %#v: %v", c, err)
+		}
+	}
+}
+
+func TestEscapeTag(t *testing.T) {
+	var tags = []string{
+		"cat", "tag with space", "OR", "it's", `back\slash`, "(paren", "paren)",
+		"saber_(fate)", `"quoted"`, "size:big", "re:zero",
+		// Older tags with names that are reserved now.
+		"-dash", "artist:", "artist:*",
+	}
+
+	for _, tag := range tags {
+		q, err := ParsePostQuery(EscapeTag(tag))
+		if err != nil {
+			t.Fatalf("Failed to parse escaped tag %q: %v", tag, err)
+		}
+
+		if q.Expr != QueryTag(tag) {
+			t.Errorf("Escaped tag %q parsed as %#v", tag, q.Expr)
+		}
+	}
+}